@@ -60,7 +60,7 @@ func main() {
 
 	authSvc := authapp.NewService(pg, cfg.JWTSecret, cfg.JWTTTL)
 	charSvc := charapp.NewService(pg, redisClient, cfg.CharacterTTL, publisher, cfg.WorldZoneID)
-	worldSvc := worldapp.NewService(logger, publisher, charSvc, cfg.WorldZoneID, cfg.WorldTickRate, cfg.WorldMapFile)
+	worldSvc := worldapp.NewService(logger, publisher, charSvc, cfg.WorldZoneID, cfg.WorldTickRate, cfg.WorldDataDir)
 	worldSvc.Start()
 	defer worldSvc.Stop()
 
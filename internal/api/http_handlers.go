@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"strings"
 	"time"
@@ -17,6 +18,8 @@ import (
 	authapp "mmorp-server/internal/app/auth"
 	charapp "mmorp-server/internal/app/character"
 	worldapp "mmorp-server/internal/app/world"
+	"mmorp-server/internal/domain/character"
+	domainworld "mmorp-server/internal/domain/world"
 )
 
 type Handler struct {
@@ -52,7 +55,9 @@ func (h *Handler) Router() http.Handler {
 		v1.Post("/auth/login", h.login)
 		v1.Get("/world/state", h.worldState)
 		v1.Get("/world/players", h.worldPlayers)
+		v1.Get("/world/zones", h.worldZones)
 		v1.Get("/world/ws", h.worldWS)
+		v1.Get("/classes", h.listClasses)
 
 		v1.Group(func(protected chi.Router) {
 			protected.Use(h.authMiddleware)
@@ -147,7 +152,15 @@ func (h *Handler) createCharacter(w http.ResponseWriter, r *http.Request) {
 	if !h.decodeBody(w, r, &req) {
 		return
 	}
-	c, err := h.characters.Create(r.Context(), uid, req.Name, req.Class)
+	if strings.TrimSpace(req.Class) == "" {
+		req.Class = character.DefaultClass
+	}
+	cls, ok := h.world.LookupClass(req.Class)
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"error": fmt.Sprintf("unknown class %q", req.Class)})
+		return
+	}
+	c, err := h.characters.Create(r.Context(), uid, req.Name, cls)
 	if err != nil {
 		writeJSON(w, http.StatusBadRequest, map[string]any{"error": err.Error()})
 		return
@@ -155,6 +168,10 @@ func (h *Handler) createCharacter(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusCreated, c)
 }
 
+func (h *Handler) listClasses(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{"items": h.world.Classes()})
+}
+
 func (h *Handler) getCharacter(w http.ResponseWriter, r *http.Request) {
 	uid, ok := userIDFromCtx(r.Context())
 	if !ok {
@@ -182,8 +199,22 @@ func (h *Handler) getCharacter(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, c)
 }
 
-func (h *Handler) worldState(w http.ResponseWriter, _ *http.Request) {
-	writeJSON(w, http.StatusOK, h.world.WorldState())
+func (h *Handler) worldState(w http.ResponseWriter, r *http.Request) {
+	zoneID := r.URL.Query().Get("zone_id")
+	if zoneID == "" {
+		writeJSON(w, http.StatusOK, h.world.WorldState())
+		return
+	}
+	state, ok := h.world.ZoneState(zoneID)
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]any{"error": "zone not found"})
+		return
+	}
+	writeJSON(w, http.StatusOK, state)
+}
+
+func (h *Handler) worldZones(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{"zones": h.world.ZoneIDs()})
 }
 
 func (h *Handler) worldPlayers(w http.ResponseWriter, _ *http.Request) {
@@ -241,10 +272,24 @@ func (h *Handler) readPump(ctx context.Context, client *worldapp.Client) {
 			Type        string  `json:"type"`
 			CharacterID string  `json:"character_id"`
 			DX          float64 `json:"dx"`
+			Seq         uint64  `json:"seq"`
 			DY          float64 `json:"dy"`
 			TargetID    string  `json:"target_id"`
 			NpcId       string  `json:"npcId"`
 			Action      string  `json:"action"`
+			Slot        int     `json:"slot"`
+			ToSlot      int     `json:"to_slot"`
+			Quantity    int     `json:"quantity"`
+			LootID      string  `json:"loot_id"`
+			EquipSlot   string  `json:"equip_slot"`
+			ItemID      string  `json:"item_id"`
+			QuestID     string  `json:"quest_id"`
+			Choice      int     `json:"choice"`
+			Channel     string  `json:"channel"`
+			Text        string  `json:"text"`
+			To          string  `json:"to"`
+			Name        string  `json:"name"`
+			AbilityID   string  `json:"ability_id"`
 		}
 		if err := client.Conn.ReadJSON(&msg); err != nil {
 			return
@@ -262,21 +307,101 @@ func (h *Handler) readPump(ctx context.Context, client *worldapp.Client) {
 				h.sendError(client, "character not found")
 				continue
 			}
-			h.world.Join(client, char)
+			if err := h.world.Join(ctx, client, char); err != nil {
+				h.logger.Warn().Err(err).Str("character_id", cid.String()).Msg("join failed")
+				h.sendError(client, "failed to load character")
+			}
 		case "move":
-			h.world.Move(client, msg.DX, msg.DY)
+			h.world.Move(client, msg.DX, msg.DY, msg.Seq)
 		case "attack":
 			if strings.TrimSpace(msg.TargetID) == "" {
 				h.sendError(client, "target_id is required")
 				continue
 			}
 			h.world.Attack(client, msg.TargetID)
+		case "cast":
+			if strings.TrimSpace(msg.AbilityID) == "" {
+				h.sendError(client, "ability_id is required")
+				continue
+			}
+			h.world.Cast(client, msg.AbilityID, msg.TargetID)
 		case "interact":
 			if strings.TrimSpace(msg.NpcId) == "" || strings.TrimSpace(msg.Action) == "" {
 				h.sendError(client, "npcId and action are required")
 				continue
 			}
-			h.world.Interact(client, msg.NpcId, msg.Action)
+			h.world.Interact(client, msg.NpcId, msg.Action, worldapp.InteractArgs{ItemID: msg.ItemID, Slot: msg.Slot, Quantity: msg.Quantity, QuestID: msg.QuestID})
+		case "dialogue_choice":
+			if strings.TrimSpace(msg.NpcId) == "" {
+				h.sendError(client, "npcId is required")
+				continue
+			}
+			h.world.DialogueChoice(client, msg.NpcId, msg.Choice)
+		case "quest_log":
+			h.world.QuestLog(client)
+		case "rest":
+			h.world.Rest(client)
+		case "release":
+			h.world.Release(client)
+		case "reclaim":
+			h.world.Reclaim(client)
+		case "resurrect":
+			if strings.TrimSpace(msg.TargetID) == "" {
+				h.sendError(client, "target_id is required")
+				continue
+			}
+			h.world.Resurrect(client, msg.TargetID)
+		case "resurrect_accept":
+			h.world.AcceptResurrect(client)
+		case "chat":
+			channel := worldapp.ChatChannel(strings.ToLower(msg.Channel))
+			if !channel.Valid() {
+				h.sendError(client, "invalid chat channel")
+				continue
+			}
+			if channel == worldapp.ChatChannelWhisper && strings.TrimSpace(msg.To) == "" {
+				h.sendError(client, "to is required for whispers")
+				continue
+			}
+			h.world.Chat(client, channel, msg.Text, msg.To)
+		case "party_invite", "party_kick":
+			if strings.TrimSpace(msg.Name) == "" {
+				h.sendError(client, "name is required")
+				continue
+			}
+			if msg.Type == "party_invite" {
+				h.world.PartyInvite(client, msg.Name)
+			} else {
+				h.world.PartyKick(client, msg.Name)
+			}
+		case "party_accept":
+			h.world.PartyAccept(client)
+		case "party_decline":
+			h.world.PartyDecline(client)
+		case "party_leave":
+			h.world.PartyLeave(client)
+		case "inventory":
+			h.world.ListInventory(client)
+		case "inventory_move":
+			h.world.MoveItem(client, msg.Slot, msg.ToSlot)
+		case "inventory_drop":
+			h.world.DropItem(client, msg.Slot, msg.Quantity)
+		case "inventory_use":
+			h.world.UseItem(client, msg.Slot)
+		case "equip":
+			h.world.Equip(client, msg.Slot)
+		case "unequip":
+			if !domainworld.EquipSlot(msg.EquipSlot).Valid() {
+				h.sendError(client, "invalid equip_slot")
+				continue
+			}
+			h.world.Unequip(client, domainworld.EquipSlot(msg.EquipSlot))
+		case "loot_pickup":
+			if strings.TrimSpace(msg.LootID) == "" {
+				h.sendError(client, "loot_id is required")
+				continue
+			}
+			h.world.PickupLoot(client, msg.LootID)
 		default:
 			h.sendError(client, "unknown message type")
 		}
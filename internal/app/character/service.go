@@ -32,21 +32,18 @@ func NewService(db *pgxpool.Pool, cache *redis.Client, cacheTTL time.Duration, p
 	return &Service{db: db, cache: cache, cacheTTL: cacheTTL, pub: pub, zoneID: zoneID}
 }
 
-func (s *Service) Create(ctx context.Context, userID uuid.UUID, name, class string) (character.Character, error) {
+// Create stores a new character of a class resolved from the world's class
+// catalog.
+func (s *Service) Create(ctx context.Context, userID uuid.UUID, name string, cls character.Class) (character.Character, error) {
 	name = strings.TrimSpace(name)
 	if name == "" {
 		return character.Character{}, fmt.Errorf("name required")
 	}
-	if class == "" {
-		class = "adventurer"
-	}
 	id := uuid.New()
-	var c character.Character
-	err := s.db.QueryRow(ctx, `
+	c, err := scanCharacter(s.db.QueryRow(ctx, `
 INSERT INTO characters (id, user_id, name, class, zone_id, pos_x, pos_y)
 VALUES ($1, $2, $3, $4, $5, 0, 0)
-RETURNING id, user_id, name, class, zone_id, pos_x, pos_y, created_at
-`, id, userID, name, class, s.zoneID).Scan(&c.ID, &c.UserID, &c.Name, &c.Class, &c.ZoneID, &c.PosX, &c.PosY, &c.CreatedAt)
+RETURNING `+characterColumns, id, userID, name, cls.ID, s.zoneID))
 	if err != nil {
 		return character.Character{}, fmt.Errorf("insert character: %w", err)
 	}
@@ -68,7 +65,7 @@ func (s *Service) ListByUser(ctx context.Context, userID uuid.UUID) ([]character
 	}
 
 	rows, err := s.db.Query(ctx, `
-SELECT id, user_id, name, class, zone_id, pos_x, pos_y, created_at
+SELECT `+characterColumns+`
 FROM characters WHERE user_id = $1 ORDER BY created_at ASC
 `, userID)
 	if err != nil {
@@ -78,8 +75,8 @@ FROM characters WHERE user_id = $1 ORDER BY created_at ASC
 
 	chars := make([]character.Character, 0)
 	for rows.Next() {
-		var c character.Character
-		if err := rows.Scan(&c.ID, &c.UserID, &c.Name, &c.Class, &c.ZoneID, &c.PosX, &c.PosY, &c.CreatedAt); err != nil {
+		c, err := scanCharacter(rows)
+		if err != nil {
 			return nil, fmt.Errorf("scan character: %w", err)
 		}
 		chars = append(chars, c)
@@ -96,11 +93,10 @@ FROM characters WHERE user_id = $1 ORDER BY created_at ASC
 }
 
 func (s *Service) GetByIDForUser(ctx context.Context, userID, characterID uuid.UUID) (character.Character, error) {
-	var c character.Character
-	err := s.db.QueryRow(ctx, `
-SELECT id, user_id, name, class, zone_id, pos_x, pos_y, created_at
+	c, err := scanCharacter(s.db.QueryRow(ctx, `
+SELECT `+characterColumns+`
 FROM characters WHERE id = $1
-`, characterID).Scan(&c.ID, &c.UserID, &c.Name, &c.Class, &c.ZoneID, &c.PosX, &c.PosY, &c.CreatedAt)
+`, characterID))
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return character.Character{}, ErrNotFound
@@ -129,6 +125,177 @@ WHERE id = $4 AND user_id = $5
 	return nil
 }
 
+func (s *Service) LoadInventory(ctx context.Context, userID, characterID uuid.UUID) ([]character.InventorySlot, error) {
+	rows, err := s.db.Query(ctx, `
+SELECT i.slot, i.item_id, i.quantity, i.wear
+FROM character_inventory i
+JOIN characters c ON c.id = i.character_id
+WHERE i.character_id = $1 AND c.user_id = $2
+ORDER BY i.slot ASC
+`, characterID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("query inventory: %w", err)
+	}
+	defer rows.Close()
+
+	slots := make([]character.InventorySlot, 0)
+	for rows.Next() {
+		var slot character.InventorySlot
+		if err := rows.Scan(&slot.Slot, &slot.ItemID, &slot.Quantity, &slot.Wear); err != nil {
+			return nil, fmt.Errorf("scan inventory slot: %w", err)
+		}
+		slots = append(slots, slot)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate inventory: %w", err)
+	}
+	return slots, nil
+}
+
+func (s *Service) LoadEquipment(ctx context.Context, userID, characterID uuid.UUID) ([]character.EquippedItem, error) {
+	rows, err := s.db.Query(ctx, `
+SELECT e.slot, e.item_id, e.wear
+FROM character_equipment e
+JOIN characters c ON c.id = e.character_id
+WHERE e.character_id = $1 AND c.user_id = $2
+`, characterID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("query equipment: %w", err)
+	}
+	defer rows.Close()
+
+	equipped := make([]character.EquippedItem, 0)
+	for rows.Next() {
+		var e character.EquippedItem
+		if err := rows.Scan(&e.Slot, &e.ItemID, &e.Wear); err != nil {
+			return nil, fmt.Errorf("scan equipment: %w", err)
+		}
+		equipped = append(equipped, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate equipment: %w", err)
+	}
+	return equipped, nil
+}
+
+// SaveCharacter writes progress and replaces the stored inventory, equipment
+// and quest log of a character in one transaction, so quest rewards can never
+// be saved apart from the quest they complete.
+func (s *Service) SaveCharacter(ctx context.Context, userID, characterID uuid.UUID, p character.Progress, slots []character.InventorySlot, equipped []character.EquippedItem, quests []character.QuestProgress) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin save tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if err := lockOwnedCharacter(ctx, tx, userID, characterID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, `
+UPDATE characters
+SET level = $1, experience = $2, gold = $3, hp = $4, max_hp = $5, xp_debt = $6, updated_at = NOW()
+WHERE id = $7
+`, p.Level, p.Experience, p.Gold, p.HP, p.MaxHP, p.XPDebt, characterID); err != nil {
+		return fmt.Errorf("update progress: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM character_inventory WHERE character_id = $1`, characterID); err != nil {
+		return fmt.Errorf("clear inventory: %w", err)
+	}
+	for _, slot := range slots {
+		if _, err := tx.Exec(ctx, `
+INSERT INTO character_inventory (character_id, slot, item_id, quantity, wear)
+VALUES ($1, $2, $3, $4, $5)
+`, characterID, slot.Slot, slot.ItemID, slot.Quantity, slot.Wear); err != nil {
+			return fmt.Errorf("insert inventory slot %d: %w", slot.Slot, err)
+		}
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM character_equipment WHERE character_id = $1`, characterID); err != nil {
+		return fmt.Errorf("clear equipment: %w", err)
+	}
+	for _, e := range equipped {
+		if _, err := tx.Exec(ctx, `
+INSERT INTO character_equipment (character_id, slot, item_id, wear)
+VALUES ($1, $2, $3, $4)
+`, characterID, e.Slot, e.ItemID, e.Wear); err != nil {
+			return fmt.Errorf("insert equipment %s: %w", e.Slot, err)
+		}
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM character_quests WHERE character_id = $1`, characterID); err != nil {
+		return fmt.Errorf("clear quests: %w", err)
+	}
+	for _, q := range quests {
+		progress := make([]int32, len(q.Progress))
+		for i, p := range q.Progress {
+			progress[i] = int32(p)
+		}
+		if _, err := tx.Exec(ctx, `
+INSERT INTO character_quests (character_id, quest_id, status, progress)
+VALUES ($1, $2, $3, $4)
+`, characterID, q.QuestID, q.Status, progress); err != nil {
+			return fmt.Errorf("insert quest %s: %w", q.QuestID, err)
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit save: %w", err)
+	}
+	s.invalidateCharacterList(ctx, userID)
+	return nil
+}
+
+func (s *Service) LoadQuests(ctx context.Context, userID, characterID uuid.UUID) ([]character.QuestProgress, error) {
+	rows, err := s.db.Query(ctx, `
+SELECT q.quest_id, q.status, q.progress
+FROM character_quests q
+JOIN characters c ON c.id = q.character_id
+WHERE q.character_id = $1 AND c.user_id = $2
+ORDER BY q.quest_id ASC
+`, characterID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("query quests: %w", err)
+	}
+	defer rows.Close()
+
+	quests := make([]character.QuestProgress, 0)
+	for rows.Next() {
+		var q character.QuestProgress
+		var progress []int32
+		if err := rows.Scan(&q.QuestID, &q.Status, &progress); err != nil {
+			return nil, fmt.Errorf("scan quest: %w", err)
+		}
+		q.Progress = make([]int, len(progress))
+		for i, p := range progress {
+			q.Progress[i] = int(p)
+		}
+		quests = append(quests, q)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate quests: %w", err)
+	}
+	return quests, nil
+}
+
+func lockOwnedCharacter(ctx context.Context, tx pgx.Tx, userID, characterID uuid.UUID) error {
+	var owner uuid.UUID
+	if err := tx.QueryRow(ctx, `SELECT user_id FROM characters WHERE id = $1 FOR UPDATE`, characterID).Scan(&owner); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("lock character: %w", err)
+	}
+	if owner != userID {
+		return ErrForbidden
+	}
+	return nil
+}
+
+const characterColumns = `id, user_id, name, class, zone_id, pos_x, pos_y, level, experience, gold, hp, max_hp, xp_debt, created_at`
+
+func scanCharacter(row pgx.Row) (character.Character, error) {
+	var c character.Character
+	err := row.Scan(&c.ID, &c.UserID, &c.Name, &c.Class, &c.ZoneID, &c.PosX, &c.PosY, &c.Level, &c.Experience, &c.Gold, &c.HP, &c.MaxHP, &c.XPDebt, &c.CreatedAt)
+	return c, err
+}
+
 func (s *Service) cacheKey(userID uuid.UUID) string {
 	return "characters:user:" + userID.String()
 }
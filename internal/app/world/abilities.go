@@ -0,0 +1,243 @@
+package world
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/google/uuid"
+
+	"mmorp-server/internal/domain/character"
+	domainworld "mmorp-server/internal/domain/world"
+)
+
+const (
+	globalCooldownTicks = 10
+	// abilityRangeSlack lets a cast finish against a target that drifted
+	// just out of range while it was being cast.
+	abilityRangeSlack = 1.0
+)
+
+// castState is an ability being cast. Moving away from StartX/StartY before
+// CompleteTick interrupts it.
+type castState struct {
+	Ability      domainworld.Ability
+	TargetID     string
+	StartX       float64
+	StartY       float64
+	CompleteTick uint64
+}
+
+func abilityAmount(a domainworld.Ability, attackPower int) int {
+	return a.Power + int(math.Round(float64(attackPower)*a.AttackPowerScale))
+}
+
+// classAbilities lists the ability definitions a class can cast, in the
+// class's order.
+func (s *Service) classAbilities(cls character.Class) []domainworld.Ability {
+	ids := cls.Abilities
+	out := make([]domainworld.Ability, 0, len(ids))
+	for _, id := range ids {
+		if a, ok := s.abilities[id]; ok {
+			out = append(out, a)
+		}
+	}
+	return out
+}
+
+func (s *Service) Cast(c *Client, abilityID, targetID string) {
+	z := s.zoneOf(c.CharacterID)
+	if z == nil {
+		return
+	}
+	z.mu.Lock()
+	pr, ok := z.players[c.CharacterID]
+	if !ok {
+		z.mu.Unlock()
+		return
+	}
+	events, self, err := s.castLocked(z, pr, abilityID, targetID)
+	z.mu.Unlock()
+
+	if err != nil {
+		nonBlockingSendJSON(c.Send, map[string]any{"type": "error", "message": err.Error()})
+		return
+	}
+	z.dispatch(events)
+	for _, msg := range self {
+		nonBlockingSendJSON(c.Send, msg)
+	}
+}
+
+// castLocked checks that the ability can be used now and either resolves it
+// at once or starts the cast. The global cooldown starts with the cast; the
+// cost and the ability's own cooldown are paid when it resolves.
+func (s *Service) castLocked(z *zone, pr *playerRuntime, abilityID, targetID string) ([]zoneEvent, []map[string]any, error) {
+	a, ok := s.abilities[abilityID]
+	if !ok || !pr.Class.HasAbility(abilityID) {
+		return nil, nil, fmt.Errorf("unknown ability")
+	}
+	switch {
+	case pr.State.HP <= 0:
+		return nil, nil, fmt.Errorf("you are dead")
+	case pr.Mods.Stunned:
+		return nil, nil, fmt.Errorf("you are stunned")
+	case pr.Cast != nil:
+		return nil, nil, fmt.Errorf("already casting")
+	case z.tick < pr.GCDUntil:
+		return nil, nil, fmt.Errorf("global cooldown")
+	case z.tick < pr.Cooldowns[abilityID]:
+		return nil, nil, fmt.Errorf("%s is on cooldown", a.Name)
+	case pr.State.Resource < a.Cost:
+		return nil, nil, fmt.Errorf("not enough %s", pr.State.ResourceType)
+	}
+	if a.Target == domainworld.AbilityTargetSelf || (a.Target == domainworld.AbilityTargetAlly && targetID == "") {
+		targetID = pr.State.ID.String()
+	}
+	if _, _, err := s.abilityTargetLocked(z, pr, a, targetID, 0); err != nil {
+		return nil, nil, err
+	}
+
+	pr.GCDUntil = z.tick + globalCooldownTicks
+	stood := standUpLocked(pr)
+	started := zoneEvent{X: pr.State.X, Y: pr.State.Y, Payload: map[string]any{
+		"type":       "ability_cast",
+		"caster_id":  pr.State.ID,
+		"ability_id": a.ID,
+		"target_id":  targetID,
+		"cast_ticks": a.CastTicks,
+	}}
+	if a.CastTicks <= 0 {
+		events, self, err := s.resolveAbilityLocked(z, pr, a, targetID)
+		if err != nil {
+			return nil, nil, err
+		}
+		return append(append(stood, started), events...), self, nil
+	}
+	pr.Cast = &castState{Ability: a, TargetID: targetID, StartX: pr.State.X, StartY: pr.State.Y, CompleteTick: z.tick + uint64(a.CastTicks)}
+	return append(stood, started), nil, nil
+}
+
+// abilityTargetLocked resolves the target of an ability: a living mob for
+// enemy abilities, a player in the zone otherwise. It returns the target's
+// position.
+func (s *Service) abilityTargetLocked(z *zone, pr *playerRuntime, a domainworld.Ability, targetID string, slack float64) (float64, float64, error) {
+	var x, y float64
+	if a.Target == domainworld.AbilityTargetEnemy {
+		mob, ok := z.mobs[targetID]
+		if !ok || !mob.State.Alive {
+			return 0, 0, fmt.Errorf("invalid mob target")
+		}
+		if mob.State.AIState == domainworld.MobAIStateEvade {
+			return 0, 0, fmt.Errorf("target is evading")
+		}
+		x, y = mob.State.X, mob.State.Y
+	} else {
+		id, err := uuid.Parse(targetID)
+		target, ok := z.players[id]
+		if err != nil || !ok || target.State.HP <= 0 {
+			return 0, 0, fmt.Errorf("invalid player target")
+		}
+		x, y = target.State.X, target.State.Y
+	}
+	if a.Target != domainworld.AbilityTargetSelf && distance(pr.State.X, pr.State.Y, x, y) > a.Range+slack {
+		return 0, 0, fmt.Errorf("target out of range")
+	}
+	return x, y, nil
+}
+
+// resolveAbilityLocked pays for the ability and applies it and its effects
+// to its target, or to every mob within its radius.
+func (s *Service) resolveAbilityLocked(z *zone, pr *playerRuntime, a domainworld.Ability, targetID string) ([]zoneEvent, []map[string]any, error) {
+	x, y, err := s.abilityTargetLocked(z, pr, a, targetID, abilityRangeSlack)
+	if err != nil {
+		return nil, nil, err
+	}
+	pr.State.Resource -= a.Cost
+	if a.CooldownTicks > 0 {
+		if pr.Cooldowns == nil {
+			pr.Cooldowns = make(map[string]uint64)
+		}
+		pr.Cooldowns[a.ID] = z.tick + uint64(a.CooldownTicks)
+	}
+	amount := abilityAmount(a, pr.State.Stats.AttackPower)
+
+	events := make([]zoneEvent, 0)
+	var self []map[string]any
+	if a.Kind == domainworld.AbilityKindHeal {
+		id, _ := uuid.Parse(targetID)
+		target := z.players[id]
+		healed := min(amount, target.State.MaxHP-target.State.HP)
+		target.State.HP += healed
+		z.addHealThreatLocked(pr.State.ID, target.State.ID, healed)
+		events = append(events, zoneEvent{X: x, Y: y, Payload: map[string]any{
+			"type": "ability_hit", "caster_id": pr.State.ID, "ability_id": a.ID, "target_id": targetID, "heal": healed, "hp": target.State.HP,
+		}})
+		for _, id := range a.Effects {
+			events = append(events, z.applyPlayerEffectLocked(target, id, pr.State.ID.String())...)
+		}
+		if target != pr {
+			nonBlockingSendJSON(target.Client.Send, map[string]any{"type": "player_update", "player": target.State})
+		}
+		return events, []map[string]any{{"type": "player_update", "player": pr.State}}, nil
+	}
+
+	targets := []*mobRuntime{z.mobs[targetID]}
+	if a.Radius > 0 {
+		targets = targets[:0]
+		for _, mob := range z.mobsNearLocked(x, y, a.Radius) {
+			if mob.State.Alive && mob.State.AIState != domainworld.MobAIStateEvade {
+				targets = append(targets, mob)
+			}
+		}
+	}
+	for _, mob := range targets {
+		mobX, mobY := mob.State.X, mob.State.Y
+		killEvents, kill := s.damageMobLocked(z, pr, mob, amount)
+		events = append(events, zoneEvent{X: mobX, Y: mobY, Payload: map[string]any{
+			"type": "ability_hit", "caster_id": pr.State.ID, "ability_id": a.ID, "target_id": mob.State.ID, "damage": amount, "hp": max(mob.State.HP, 0),
+		}})
+		events = append(events, killEvents...)
+		self = append(self, kill...)
+		for _, id := range a.Effects {
+			events = append(events, z.applyMobEffectLocked(mob, id, pr.State.ID.String())...)
+		}
+	}
+	return events, append(self, map[string]any{"type": "player_update", "player": pr.State}), nil
+}
+
+// stepCastsLocked finishes casts whose time is up and interrupts casters who
+// moved or were stunned. Messages for individual players are sent directly;
+// zone events are returned.
+func (s *Service) stepCastsLocked(z *zone) []zoneEvent {
+	events := make([]zoneEvent, 0)
+	for _, pr := range z.players {
+		cast := pr.Cast
+		if cast == nil {
+			continue
+		}
+		if pr.State.X != cast.StartX || pr.State.Y != cast.StartY || pr.State.HP <= 0 || pr.Mods.Stunned {
+			pr.Cast = nil
+			events = append(events, zoneEvent{X: pr.State.X, Y: pr.State.Y, Payload: map[string]any{
+				"type": "ability_interrupted", "caster_id": pr.State.ID, "ability_id": cast.Ability.ID,
+			}})
+			continue
+		}
+		if z.tick < cast.CompleteTick {
+			continue
+		}
+		pr.Cast = nil
+		resolved, self, err := s.resolveAbilityLocked(z, pr, cast.Ability, cast.TargetID)
+		if err != nil {
+			nonBlockingSendJSON(pr.Client.Send, map[string]any{"type": "error", "message": err.Error()})
+			events = append(events, zoneEvent{X: pr.State.X, Y: pr.State.Y, Payload: map[string]any{
+				"type": "ability_interrupted", "caster_id": pr.State.ID, "ability_id": cast.Ability.ID,
+			}})
+			continue
+		}
+		events = append(events, resolved...)
+		for _, msg := range self {
+			nonBlockingSendJSON(pr.Client.Send, msg)
+		}
+	}
+	return events
+}
@@ -0,0 +1,524 @@
+package world
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"mmorp-server/internal/domain/character"
+	domainworld "mmorp-server/internal/domain/world"
+)
+
+const (
+	mobDefaultLevel          = 1
+	mobDefaultMoveSpeed      = 0.18
+	mobDefaultAggroRange     = 6.0
+	mobDefaultAttackRange    = 1.1
+	mobDefaultAttackCooldown = 7
+	mobDefaultRespawnTicks   = 50
+
+	effectDefaultIntervalTicks = 10
+)
+
+type catalog struct {
+	items     map[string]domainworld.Item
+	mobs      map[string]domainworld.MobTemplate
+	quests    map[string]domainworld.Quest
+	dialogues map[string]domainworld.Dialogue
+}
+
+type ItemCatalogJSON struct {
+	Items []domainworld.Item `json:"items"`
+}
+
+func loadItems(path string) (map[string]domainworld.Item, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read item catalog: %w", err)
+	}
+	var data ItemCatalogJSON
+	if err := json.Unmarshal(b, &data); err != nil {
+		return nil, fmt.Errorf("parse item catalog json: %w", err)
+	}
+	items := make(map[string]domainworld.Item, len(data.Items))
+	for _, it := range data.Items {
+		if it.ID == "" {
+			return nil, fmt.Errorf("item without id")
+		}
+		if _, dup := items[it.ID]; dup {
+			return nil, fmt.Errorf("item %q defined twice", it.ID)
+		}
+		if it.Name == "" {
+			it.Name = it.ID
+		}
+		if it.Kind == "" {
+			it.Kind = domainworld.ItemKindMaterial
+		}
+		if it.Rarity == "" {
+			it.Rarity = domainworld.ItemRarityCommon
+		}
+		if !it.Rarity.Valid() {
+			return nil, fmt.Errorf("item %q has unknown rarity %q", it.ID, it.Rarity)
+		}
+		if it.MaxStack <= 0 {
+			it.MaxStack = 1
+		}
+		if it.Slot == "" {
+			switch it.Kind {
+			case domainworld.ItemKindWeapon:
+				it.Slot = domainworld.EquipSlotWeapon
+			case domainworld.ItemKindTrinket:
+				it.Slot = domainworld.EquipSlotTrinket
+			}
+		}
+		if it.Slot != "" && !it.Slot.Valid() {
+			return nil, fmt.Errorf("item %q has unknown slot %q", it.ID, it.Slot)
+		}
+		if it.Kind == domainworld.ItemKindArmor && it.Slot == "" {
+			return nil, fmt.Errorf("armor item %q needs a slot", it.ID)
+		}
+		if it.Value < 0 {
+			return nil, fmt.Errorf("item %q has negative value", it.ID)
+		}
+		if it.Durability > 0 && it.MaxStack > 1 {
+			return nil, fmt.Errorf("item %q has durability and cannot stack", it.ID)
+		}
+		items[it.ID] = it
+	}
+	return items, nil
+}
+
+type MobCatalogJSON struct {
+	Mobs []domainworld.MobTemplate `json:"mobs"`
+}
+
+func loadMobTemplates(path string) (map[string]domainworld.MobTemplate, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read mob catalog: %w", err)
+	}
+	var data MobCatalogJSON
+	if err := json.Unmarshal(b, &data); err != nil {
+		return nil, fmt.Errorf("parse mob catalog json: %w", err)
+	}
+	templates := make(map[string]domainworld.MobTemplate, len(data.Mobs))
+	for _, t := range data.Mobs {
+		if t.ID == "" {
+			return nil, fmt.Errorf("mob template without id")
+		}
+		if _, dup := templates[t.ID]; dup {
+			return nil, fmt.Errorf("mob template %q defined twice", t.ID)
+		}
+		if t.HP <= 0 || t.Damage < 0 {
+			return nil, fmt.Errorf("mob template %q needs positive hp and non-negative damage", t.ID)
+		}
+		loot, err := normalizeLootTable(t.Loot)
+		if err != nil {
+			return nil, fmt.Errorf("mob template %q loot: %w", t.ID, err)
+		}
+		t.Loot = loot
+		t = withMobDefaults(t)
+		attacks, err := normalizeMobAttacks(t)
+		if err != nil {
+			return nil, fmt.Errorf("mob template %q attacks: %w", t.ID, err)
+		}
+		t.Attacks = attacks
+		templates[t.ID] = t
+	}
+	return templates, nil
+}
+
+func withMobDefaults(t domainworld.MobTemplate) domainworld.MobTemplate {
+	if t.Name == "" {
+		t.Name = t.ID
+	}
+	if t.Level <= 0 {
+		t.Level = mobDefaultLevel
+	}
+	if t.MoveSpeed <= 0 {
+		t.MoveSpeed = mobDefaultMoveSpeed
+	}
+	if t.AggroRange <= 0 {
+		t.AggroRange = mobDefaultAggroRange
+	}
+	if t.AttackRange <= 0 {
+		t.AttackRange = mobDefaultAttackRange
+	}
+	if t.AttackCooldownTicks <= 0 {
+		t.AttackCooldownTicks = mobDefaultAttackCooldown
+	}
+	if t.RespawnTicks <= 0 {
+		t.RespawnTicks = mobDefaultRespawnTicks
+	}
+	if t.XPReward < 0 {
+		t.XPReward = 0
+	}
+	if len(t.Attacks) == 0 {
+		t.Attacks = []domainworld.MobAttack{{
+			ID:            "attack",
+			Name:          "Attack",
+			Kind:          domainworld.MobAttackMelee,
+			Range:         t.AttackRange,
+			CooldownTicks: t.AttackCooldownTicks,
+		}}
+	}
+	return t
+}
+
+// normalizeMobAttacks fills in attack defaults from the template and checks
+// that the mob always has an attack it can use on its target.
+func normalizeMobAttacks(t domainworld.MobTemplate) ([]domainworld.MobAttack, error) {
+	attacks := make([]domainworld.MobAttack, 0, len(t.Attacks))
+	seen := make(map[string]struct{}, len(t.Attacks))
+	usable := false
+	for _, a := range t.Attacks {
+		if a.ID == "" {
+			return nil, fmt.Errorf("attack without id")
+		}
+		if _, dup := seen[a.ID]; dup {
+			return nil, fmt.Errorf("attack %q defined twice", a.ID)
+		}
+		seen[a.ID] = struct{}{}
+		if a.HPBelow < 0 || a.HPBelow >= 1 {
+			return nil, fmt.Errorf("attack %q needs hp_below between 0 and 1", a.ID)
+		}
+		if a.Damage < 0 {
+			return nil, fmt.Errorf("attack %q has negative damage", a.ID)
+		}
+		switch a.Kind {
+		case domainworld.MobAttackMelee:
+			if a.Range <= 0 {
+				a.Range = t.AttackRange
+			}
+		case domainworld.MobAttackRanged:
+			if a.Range <= 0 {
+				return nil, fmt.Errorf("ranged attack %q needs a range", a.ID)
+			}
+		case domainworld.MobAttackSlam:
+			if a.Radius <= 0 {
+				return nil, fmt.Errorf("slam %q needs a radius", a.ID)
+			}
+			a.Range = a.Radius
+		case domainworld.MobAttackEnrage:
+			if a.HPBelow <= 0 || len(a.Effects) == 0 {
+				return nil, fmt.Errorf("enrage %q needs hp_below and effects", a.ID)
+			}
+		default:
+			return nil, fmt.Errorf("attack %q has unknown kind %q", a.ID, a.Kind)
+		}
+		if a.CooldownTicks <= 0 && a.Kind != domainworld.MobAttackEnrage {
+			a.CooldownTicks = t.AttackCooldownTicks
+		}
+		if a.Name == "" {
+			a.Name = a.ID
+		}
+		if a.Kind != domainworld.MobAttackEnrage && a.HPBelow == 0 {
+			usable = true
+		}
+		attacks = append(attacks, a)
+	}
+	if !usable {
+		return nil, fmt.Errorf("no attack usable at full health")
+	}
+	return attacks, nil
+}
+
+type QuestCatalogJSON struct {
+	Quests []domainworld.Quest `json:"quests"`
+}
+
+// loadQuests reads quest definitions and checks that every objective and
+// reward refers to a known mob template or item. NPC references are checked
+// once the maps are loaded.
+func loadQuests(path string, cat catalog) (map[string]domainworld.Quest, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read quest catalog: %w", err)
+	}
+	var data QuestCatalogJSON
+	if err := json.Unmarshal(b, &data); err != nil {
+		return nil, fmt.Errorf("parse quest catalog json: %w", err)
+	}
+	quests := make(map[string]domainworld.Quest, len(data.Quests))
+	for _, q := range data.Quests {
+		if q.ID == "" || q.GiverID == "" {
+			return nil, fmt.Errorf("quest needs an id and a giver")
+		}
+		if _, dup := quests[q.ID]; dup {
+			return nil, fmt.Errorf("quest %q defined twice", q.ID)
+		}
+		if len(q.Objectives) == 0 {
+			return nil, fmt.Errorf("quest %q has no objectives", q.ID)
+		}
+		if q.Name == "" {
+			q.Name = q.ID
+		}
+		if q.TurnInID == "" {
+			q.TurnInID = q.GiverID
+		}
+		if q.MinLevel <= 0 {
+			q.MinLevel = 1
+		}
+		objectives := make([]domainworld.QuestObjective, 0, len(q.Objectives))
+		for _, o := range q.Objectives {
+			if o.Count <= 0 {
+				o.Count = 1
+			}
+			switch o.Type {
+			case domainworld.QuestObjectiveKill:
+				if _, ok := cat.mobs[o.Target]; !ok {
+					return nil, fmt.Errorf("quest %q kills unknown mob template %q", q.ID, o.Target)
+				}
+			case domainworld.QuestObjectiveCollect:
+				if _, ok := cat.items[o.Target]; !ok {
+					return nil, fmt.Errorf("quest %q collects unknown item %q", q.ID, o.Target)
+				}
+			case domainworld.QuestObjectiveTalk:
+				o.Count = 1
+			default:
+				return nil, fmt.Errorf("quest %q has unknown objective type %q", q.ID, o.Type)
+			}
+			objectives = append(objectives, o)
+		}
+		q.Objectives = objectives
+		for _, r := range q.Rewards.Items {
+			if _, ok := cat.items[r.ItemID]; !ok || r.Quantity <= 0 {
+				return nil, fmt.Errorf("quest %q rewards invalid item %q", q.ID, r.ItemID)
+			}
+		}
+		quests[q.ID] = q
+	}
+	return quests, nil
+}
+
+type DialogueCatalogJSON struct {
+	Dialogues []domainworld.Dialogue `json:"dialogues"`
+}
+
+// loadDialogues reads dialogue graphs and checks that every choice leads to
+// an existing node and every quest it mentions exists.
+func loadDialogues(path string, cat catalog) (map[string]domainworld.Dialogue, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read dialogue catalog: %w", err)
+	}
+	var data DialogueCatalogJSON
+	if err := json.Unmarshal(b, &data); err != nil {
+		return nil, fmt.Errorf("parse dialogue catalog json: %w", err)
+	}
+	dialogues := make(map[string]domainworld.Dialogue, len(data.Dialogues))
+	for _, d := range data.Dialogues {
+		if d.ID == "" {
+			return nil, fmt.Errorf("dialogue without id")
+		}
+		if _, dup := dialogues[d.ID]; dup {
+			return nil, fmt.Errorf("dialogue %q defined twice", d.ID)
+		}
+		if _, ok := d.Nodes[d.Start]; !ok {
+			return nil, fmt.Errorf("dialogue %q starts at unknown node %q", d.ID, d.Start)
+		}
+		for nodeID, node := range d.Nodes {
+			for _, ch := range node.Choices {
+				if err := validateDialogueChoice(ch, d, cat); err != nil {
+					return nil, fmt.Errorf("dialogue %q node %q: %w", d.ID, nodeID, err)
+				}
+			}
+		}
+		dialogues[d.ID] = d
+	}
+	return dialogues, nil
+}
+
+func validateDialogueChoice(ch domainworld.DialogueChoice, d domainworld.Dialogue, cat catalog) error {
+	if _, ok := d.Nodes[ch.Next]; ch.Next != "" && !ok {
+		return fmt.Errorf("choice leads to unknown node %q", ch.Next)
+	}
+	for _, cond := range ch.Conditions {
+		switch cond.Type {
+		case domainworld.DialogueConditionMinLevel, domainworld.DialogueConditionMaxLevel, domainworld.DialogueConditionMinGold:
+		case domainworld.DialogueConditionQuestStatus:
+			if _, ok := cat.quests[cond.QuestID]; !ok {
+				return fmt.Errorf("condition checks unknown quest %q", cond.QuestID)
+			}
+		default:
+			return fmt.Errorf("unknown condition type %q", cond.Type)
+		}
+	}
+	for _, a := range ch.Actions {
+		switch a.Type {
+		case domainworld.DialogueActionOpenShop:
+		case domainworld.DialogueActionGrantQuest, domainworld.DialogueActionTurnInQuest:
+			if _, ok := cat.quests[a.QuestID]; !ok {
+				return fmt.Errorf("action uses unknown quest %q", a.QuestID)
+			}
+		default:
+			return fmt.Errorf("unknown action type %q", a.Type)
+		}
+	}
+	return nil
+}
+
+type ClassCatalogJSON struct {
+	Classes []character.Class `json:"classes"`
+}
+
+func loadClasses(path string) (character.ClassCatalog, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read class catalog: %w", err)
+	}
+	var data ClassCatalogJSON
+	if err := json.Unmarshal(b, &data); err != nil {
+		return nil, fmt.Errorf("parse class catalog json: %w", err)
+	}
+	classes := make(character.ClassCatalog, len(data.Classes))
+	for _, c := range data.Classes {
+		if c.ID == "" {
+			return nil, fmt.Errorf("class without id")
+		}
+		if c.ID != strings.ToLower(c.ID) {
+			return nil, fmt.Errorf("class id %q must be lower case", c.ID)
+		}
+		if _, dup := classes[c.ID]; dup {
+			return nil, fmt.Errorf("class %q defined twice", c.ID)
+		}
+		switch c.Resource {
+		case character.ResourceMana, character.ResourceEnergy:
+		default:
+			return nil, fmt.Errorf("class %q has unknown resource %q", c.ID, c.Resource)
+		}
+		if c.BaseStats.MaxHP <= 0 || c.AttackRange <= 0 {
+			return nil, fmt.Errorf("class %q needs positive max_hp and attack_range", c.ID)
+		}
+		if c.Name == "" {
+			c.Name = c.ID
+		}
+		classes[c.ID] = c
+	}
+	if _, ok := classes[character.DefaultClass]; !ok {
+		return nil, fmt.Errorf("default class %q missing", character.DefaultClass)
+	}
+	return classes, nil
+}
+
+// fallbackClasses keeps the world playable without a class catalog: every
+// character is an adventurer with basic attacks only.
+func fallbackClasses() character.ClassCatalog {
+	return character.ClassCatalog{character.DefaultClass: {
+		ID:            character.DefaultClass,
+		Name:          "Adventurer",
+		BaseStats:     domainworld.Stats{AttackPower: 20, MaxHP: 100, HPRegen: 2},
+		StatsPerLevel: domainworld.Stats{AttackPower: 3, MaxHP: 20, HPRegen: 1},
+		AttackRange:   1.3,
+		Resource:      character.ResourceEnergy,
+		BaseResource:  100,
+		ResourceRegen: 10,
+	}}
+}
+
+type AbilityCatalogJSON struct {
+	Abilities []domainworld.Ability `json:"abilities"`
+}
+
+func loadAbilities(path string) (map[string]domainworld.Ability, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read ability catalog: %w", err)
+	}
+	var data AbilityCatalogJSON
+	if err := json.Unmarshal(b, &data); err != nil {
+		return nil, fmt.Errorf("parse ability catalog json: %w", err)
+	}
+	abilities := make(map[string]domainworld.Ability, len(data.Abilities))
+	for _, a := range data.Abilities {
+		if a.ID == "" {
+			return nil, fmt.Errorf("ability without id")
+		}
+		if _, dup := abilities[a.ID]; dup {
+			return nil, fmt.Errorf("ability %q defined twice", a.ID)
+		}
+		switch a.Kind {
+		case domainworld.AbilityKindDamage, domainworld.AbilityKindHeal:
+		default:
+			return nil, fmt.Errorf("ability %q has unknown kind %q", a.ID, a.Kind)
+		}
+		switch a.Target {
+		case domainworld.AbilityTargetEnemy, domainworld.AbilityTargetSelf, domainworld.AbilityTargetAlly:
+		default:
+			return nil, fmt.Errorf("ability %q has unknown target %q", a.ID, a.Target)
+		}
+		if a.Kind == domainworld.AbilityKindDamage && a.Target == domainworld.AbilityTargetAlly {
+			return nil, fmt.Errorf("ability %q damages an ally", a.ID)
+		}
+		if a.Kind == domainworld.AbilityKindHeal && a.Target == domainworld.AbilityTargetEnemy {
+			return nil, fmt.Errorf("ability %q heals an enemy", a.ID)
+		}
+		if a.Kind == domainworld.AbilityKindDamage && a.Target == domainworld.AbilityTargetSelf && a.Radius <= 0 {
+			return nil, fmt.Errorf("self-targeted damage ability %q needs a radius", a.ID)
+		}
+		if a.Name == "" {
+			a.Name = a.ID
+		}
+		abilities[a.ID] = a
+	}
+	return abilities, nil
+}
+
+type EffectCatalogJSON struct {
+	Effects []domainworld.StatusEffect `json:"effects"`
+}
+
+func loadEffects(path string) (map[string]domainworld.StatusEffect, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read effect catalog: %w", err)
+	}
+	var data EffectCatalogJSON
+	if err := json.Unmarshal(b, &data); err != nil {
+		return nil, fmt.Errorf("parse effect catalog json: %w", err)
+	}
+	effects := make(map[string]domainworld.StatusEffect, len(data.Effects))
+	for _, e := range data.Effects {
+		if e.ID == "" {
+			return nil, fmt.Errorf("effect without id")
+		}
+		if _, dup := effects[e.ID]; dup {
+			return nil, fmt.Errorf("effect %q defined twice", e.ID)
+		}
+		if e.DurationTicks <= 0 {
+			return nil, fmt.Errorf("effect %q needs a positive duration", e.ID)
+		}
+		switch e.Kind {
+		case domainworld.EffectKindStat, domainworld.EffectKindStun, domainworld.EffectKindRoot:
+		case domainworld.EffectKindDoT, domainworld.EffectKindHoT:
+			if e.Amount <= 0 {
+				return nil, fmt.Errorf("effect %q needs a positive amount", e.ID)
+			}
+			if e.IntervalTicks <= 0 {
+				e.IntervalTicks = effectDefaultIntervalTicks
+			}
+		case domainworld.EffectKindSlow:
+			if e.Slow <= 0 || e.Slow >= 1 {
+				return nil, fmt.Errorf("slow effect %q needs a slow between 0 and 1", e.ID)
+			}
+		default:
+			return nil, fmt.Errorf("effect %q has unknown kind %q", e.ID, e.Kind)
+		}
+		switch e.Stacking {
+		case "":
+			e.Stacking = domainworld.EffectStackingRefresh
+		case domainworld.EffectStackingRefresh, domainworld.EffectStackingStack, domainworld.EffectStackingIgnore:
+		default:
+			return nil, fmt.Errorf("effect %q has unknown stacking %q", e.ID, e.Stacking)
+		}
+		if e.MaxStacks <= 0 || e.Stacking != domainworld.EffectStackingStack {
+			e.MaxStacks = 1
+		}
+		if e.Name == "" {
+			e.Name = e.ID
+		}
+		effects[e.ID] = e
+	}
+	return effects, nil
+}
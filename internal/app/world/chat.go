@@ -0,0 +1,228 @@
+package world
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+
+	domainworld "mmorp-server/internal/domain/world"
+)
+
+const (
+	chatMaxLength = 256
+	// Each player may send chatBurst messages at once, refilled at
+	// chatRefillPerSecond.
+	chatBurst           = 5
+	chatRefillPerSecond = 1.0
+	chatPublishTimeout  = 2 * time.Second
+)
+
+type ChatChannel string
+
+const (
+	ChatChannelSay     ChatChannel = "say"
+	ChatChannelZone    ChatChannel = "zone"
+	ChatChannelWhisper ChatChannel = "whisper"
+	ChatChannelParty   ChatChannel = "party"
+)
+
+func (c ChatChannel) Valid() bool {
+	switch c {
+	case ChatChannelSay, ChatChannelZone, ChatChannelWhisper, ChatChannelParty:
+		return true
+	}
+	return false
+}
+
+// ChatFilter inspects a message before delivery. It returns the text to
+// deliver, or false to reject the message.
+type ChatFilter func(text string) (string, bool)
+
+// NewBlocklistFilter masks every whole-word, case-insensitive occurrence of
+// the given words with asterisks.
+func NewBlocklistFilter(words []string) ChatFilter {
+	quoted := make([]string, 0, len(words))
+	for _, w := range words {
+		if w = strings.TrimSpace(w); w != "" {
+			quoted = append(quoted, regexp.QuoteMeta(w))
+		}
+	}
+	if len(quoted) == 0 {
+		return nil
+	}
+	re := regexp.MustCompile(`(?i)\b(` + strings.Join(quoted, "|") + `)\b`)
+	return func(text string) (string, bool) {
+		return re.ReplaceAllStringFunc(text, func(m string) string {
+			return strings.Repeat("*", utf8.RuneCountInString(m))
+		}), true
+	}
+}
+
+type ChatConfigJSON struct {
+	BlockedWords []string `json:"blocked_words"`
+}
+
+func loadChatFilter(path string) (ChatFilter, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read chat config: %w", err)
+	}
+	var data ChatConfigJSON
+	if err := json.Unmarshal(b, &data); err != nil {
+		return nil, fmt.Errorf("parse chat config json: %w", err)
+	}
+	return NewBlocklistFilter(data.BlockedWords), nil
+}
+
+// chatLimiter is a token bucket guarding one client's chat.
+type chatLimiter struct {
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+func (l *chatLimiter) Allow(now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.last.IsZero() {
+		l.tokens = chatBurst
+	} else {
+		l.tokens = min(chatBurst, l.tokens+now.Sub(l.last).Seconds()*chatRefillPerSecond)
+	}
+	l.last = now
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	return true
+}
+
+// SetChatFilter replaces the filter applied to every chat message; nil
+// disables filtering.
+func (s *Service) SetChatFilter(f ChatFilter) {
+	s.mu.Lock()
+	s.chatFilter = f
+	s.mu.Unlock()
+}
+
+func (s *Service) Chat(c *Client, channel ChatChannel, text, to string) {
+	z := s.zoneOf(c.CharacterID)
+	if z == nil {
+		return
+	}
+	text = strings.TrimSpace(text)
+	if err := s.validateChat(c, channel, text); err != nil {
+		nonBlockingSendJSON(c.Send, map[string]any{"type": "error", "message": err.Error()})
+		return
+	}
+	s.mu.RLock()
+	filter := s.chatFilter
+	s.mu.RUnlock()
+	if filter != nil {
+		var ok bool
+		if text, ok = filter(text); !ok {
+			nonBlockingSendJSON(c.Send, map[string]any{"type": "error", "message": "message blocked"})
+			return
+		}
+	}
+
+	z.mu.RLock()
+	pr, ok := z.players[c.CharacterID]
+	var sender domainworld.PlayerState
+	if ok {
+		sender = pr.State
+	}
+	z.mu.RUnlock()
+	if !ok {
+		return
+	}
+	msg := map[string]any{
+		"type":    "chat",
+		"channel": channel,
+		"from":    sender.Name,
+		"from_id": sender.ID,
+		"text":    text,
+		"sent_at": time.Now().UTC().Format(time.RFC3339),
+		"zone_id": sender.ZoneID,
+	}
+
+	subject := fmt.Sprintf("chat.%s.%s", channel, sender.ZoneID)
+	switch channel {
+	case ChatChannelSay:
+		// Say reaches everyone who can see the speaker.
+		z.broadcastNear(uuid.Nil, sender.X, sender.Y, msg)
+	case ChatChannelZone:
+		z.broadcast(uuid.Nil, msg)
+	case ChatChannelWhisper:
+		if err := s.whisper(c, to, msg); err != nil {
+			nonBlockingSendJSON(c.Send, map[string]any{"type": "error", "message": err.Error()})
+			return
+		}
+		subject = "chat.whisper"
+	case ChatChannelParty:
+		partyID, ok := s.sendToParty(c.CharacterID, msg)
+		if !ok {
+			nonBlockingSendJSON(c.Send, map[string]any{"type": "error", "message": "you are not in a party"})
+			return
+		}
+		subject = "chat.party." + partyID
+	}
+	s.publishChat(subject, msg)
+}
+
+func (s *Service) validateChat(c *Client, channel ChatChannel, text string) error {
+	if !channel.Valid() {
+		return fmt.Errorf("unknown chat channel %q", channel)
+	}
+	if text == "" {
+		return fmt.Errorf("message is empty")
+	}
+	if utf8.RuneCountInString(text) > chatMaxLength {
+		return fmt.Errorf("message longer than %d characters", chatMaxLength)
+	}
+	if !c.chat.Allow(time.Now()) {
+		return fmt.Errorf("you are sending messages too quickly")
+	}
+	return nil
+}
+
+// whisper delivers msg to the named player and echoes it to the sender.
+func (s *Service) whisper(c *Client, to string, msg map[string]any) error {
+	s.socialMu.Lock()
+	defer s.socialMu.Unlock()
+	target, err := s.onlineByNameLocked(to)
+	if err != nil {
+		return err
+	}
+	msg["to"] = target.Name
+	nonBlockingSendJSON(target.Client.Send, msg)
+	if target.Client != c {
+		nonBlockingSendJSON(c.Send, msg)
+	}
+	return nil
+}
+
+// publishChat mirrors a chat message to NATS so other server instances and
+// moderation tooling can see it.
+func (s *Service) publishChat(subject string, msg map[string]any) {
+	if s.pub == nil {
+		return
+	}
+	b, err := json.Marshal(msg)
+	if err != nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), chatPublishTimeout)
+	defer cancel()
+	if err := s.pub.Publish(ctx, subject, b); err != nil {
+		s.logger.Warn().Err(err).Str("subject", subject).Msg("failed to publish chat message")
+	}
+}
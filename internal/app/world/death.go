@@ -0,0 +1,294 @@
+package world
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+
+	"github.com/google/uuid"
+
+	domainworld "mmorp-server/internal/domain/world"
+)
+
+// DeathRules configure what dying costs and the ways back to life. Releasing
+// respawns the player at the graveyard nearest their corpse with
+// ReleaseHPPercent of their HP and the penalties applied; with CorpseRun the
+// first release only turns them into a ghost, which can walk back and
+// reclaim the corpse for free. Other players may offer a resurrection when
+// Resurrection is on.
+type DeathRules struct {
+	ReleaseHPPercent      int     `json:"release_hp_percent"`
+	CorpseRun             bool    `json:"corpse_run"`
+	ReclaimRange          float64 `json:"reclaim_range"`
+	Resurrection          bool    `json:"resurrection"`
+	ResurrectRange        float64 `json:"resurrect_range"`
+	ResurrectHPPercent    int     `json:"resurrect_hp_percent"`
+	ResurrectOfferTicks   int     `json:"resurrect_offer_ticks"`
+	XPDebtPercent         int     `json:"xp_debt_percent"`
+	DurabilityLossPercent int     `json:"durability_loss_percent"`
+}
+
+func DefaultDeathRules() DeathRules {
+	return DeathRules{
+		ReleaseHPPercent:      50,
+		CorpseRun:             true,
+		ReclaimRange:          3,
+		Resurrection:          true,
+		ResurrectRange:        5,
+		ResurrectHPPercent:    35,
+		ResurrectOfferTicks:   600,
+		XPDebtPercent:         10,
+		DurabilityLossPercent: 10,
+	}
+}
+
+// loadDeathRules reads the rules from path. Fields the file leaves out keep
+// their defaults.
+func loadDeathRules(path string) (DeathRules, error) {
+	rules := DefaultDeathRules()
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return rules, fmt.Errorf("read death rules: %w", err)
+	}
+	if err := json.Unmarshal(b, &rules); err != nil {
+		return DefaultDeathRules(), fmt.Errorf("parse death rules json: %w", err)
+	}
+	return rules.normalized(), nil
+}
+
+func (r DeathRules) normalized() DeathRules {
+	r.ReleaseHPPercent = min(max(r.ReleaseHPPercent, 1), 100)
+	r.ResurrectHPPercent = min(max(r.ResurrectHPPercent, 1), 100)
+	r.XPDebtPercent = min(max(r.XPDebtPercent, 0), 100)
+	r.DurabilityLossPercent = min(max(r.DurabilityLossPercent, 0), 100)
+	r.ResurrectOfferTicks = max(r.ResurrectOfferTicks, 1)
+	return r
+}
+
+// SetDeathRules replaces the death rules. It must be called before Start.
+func (s *Service) SetDeathRules(r DeathRules) {
+	s.mu.Lock()
+	s.death = r.normalized()
+	s.mu.Unlock()
+}
+
+// resurrectOffer is a pending resurrection a dead player may accept until
+// ExpiresTick.
+type resurrectOffer struct {
+	FromID      uuid.UUID
+	FromName    string
+	ExpiresTick uint64
+}
+
+// killPlayerLocked leaves the player dead where they fell. Their effects,
+// rest and meal end, and mobs stop attacking them until they are revived.
+func (z *zone) killPlayerLocked(pr *playerRuntime) []zoneEvent {
+	pr.clearEffects()
+	pr.State.HP = 0
+	pr.State.Dead = true
+	pr.State.Corpse = &domainworld.SpawnPoint{X: pr.State.X, Y: pr.State.Y}
+	pr.State.Resting = false
+	pr.Meal = nil
+	pr.PendingMove = nil
+	pr.LastCombatTick = 0
+	nonBlockingSendJSON(pr.Client.Send, map[string]any{"type": "player_update", "player": pr.State})
+	return []zoneEvent{{X: pr.State.X, Y: pr.State.Y, Payload: map[string]any{"type": "player_died", "player_id": pr.State.ID, "x": pr.State.X, "y": pr.State.Y}}}
+}
+
+// nearestGraveyard is the graveyard closest to (x, y), or the zone spawn
+// when the map has none.
+func (z *zone) nearestGraveyard(x, y float64) domainworld.Graveyard {
+	best := domainworld.Graveyard{X: z.worldMap.Spawn.X, Y: z.worldMap.Spawn.Y}
+	bestDist := math.MaxFloat64
+	for _, g := range z.worldMap.Graveyards {
+		if d := distance(x, y, g.X, g.Y); d < bestDist {
+			best = g
+			bestDist = d
+		}
+	}
+	return best
+}
+
+func (z *zone) relocateLocked(pr *playerRuntime, x, y float64) zoneEvent {
+	pr.State.X = x
+	pr.State.Y = y
+	pr.PendingMove = nil
+	z.playerGrid.Upsert(pr.State.ID, x, y)
+	return zoneEvent{X: x, Y: y, Payload: map[string]any{"type": "player_moved", "player_id": pr.State.ID, "x": x, "y": y}}
+}
+
+// reviveLocked brings a dead player back at (x, y) with hpPercent of their
+// HP. payload carries the details of how for the player_respawned event.
+func (z *zone) reviveLocked(pr *playerRuntime, x, y float64, hpPercent int, payload map[string]any) []zoneEvent {
+	pr.State.Dead = false
+	pr.State.Ghost = false
+	pr.State.Corpse = nil
+	pr.Resurrect = nil
+	pr.State.HP = max(1, pr.State.MaxHP*hpPercent/100)
+	events := []zoneEvent{z.relocateLocked(pr, x, y)}
+	payload["type"] = "player_respawned"
+	payload["player_id"] = pr.State.ID
+	payload["x"] = x
+	payload["y"] = y
+	events = append(events, zoneEvent{X: x, Y: y, Payload: payload})
+	nonBlockingSendJSON(pr.Client.Send, map[string]any{"type": "player_update", "player": pr.State})
+	return events
+}
+
+// applyDeathPenaltyLocked adds XP debt worth a share of the current level
+// and wears down equipped gear.
+func (s *Service) applyDeathPenaltyLocked(pr *playerRuntime) {
+	if pct := s.death.XPDebtPercent; pct > 0 {
+		levelXP := pr.State.Level * 100
+		pr.State.XPDebt = min(levelXP, pr.State.XPDebt+(levelXP*pct+99)/100)
+	}
+	if s.death.DurabilityLossPercent <= 0 {
+		return
+	}
+	wear := make(map[domainworld.EquipSlot]int, len(pr.State.Equipment))
+	for slot, id := range pr.State.Equipment {
+		item := s.items[id]
+		if item.Durability <= 0 {
+			continue
+		}
+		loss := (item.Durability*s.death.DurabilityLossPercent + 99) / 100
+		wear[slot] = min(item.Durability, pr.State.Wear[slot]+loss)
+	}
+	if len(wear) == 0 {
+		return
+	}
+	pr.State.Wear = wear
+	refreshStats(pr, s.items)
+}
+
+// respawnLocked revives a dead player at the graveyard nearest their corpse
+// and charges the death penalty.
+func (s *Service) respawnLocked(z *zone, pr *playerRuntime) []zoneEvent {
+	g := z.nearestGraveyard(pr.State.Corpse.X, pr.State.Corpse.Y)
+	s.applyDeathPenaltyLocked(pr)
+	return z.reviveLocked(pr, g.X, g.Y, s.death.ReleaseHPPercent, map[string]any{"method": "graveyard", "graveyard_id": g.ID})
+}
+
+func (s *Service) releaseLocked(z *zone, pr *playerRuntime) ([]zoneEvent, error) {
+	if !pr.State.Dead {
+		return nil, fmt.Errorf("you are not dead")
+	}
+	if !s.death.CorpseRun || pr.State.Ghost {
+		return s.respawnLocked(z, pr), nil
+	}
+	g := z.nearestGraveyard(pr.State.Corpse.X, pr.State.Corpse.Y)
+	pr.State.Ghost = true
+	events := []zoneEvent{
+		z.relocateLocked(pr, g.X, g.Y),
+		{X: g.X, Y: g.Y, Payload: map[string]any{"type": "player_released", "player_id": pr.State.ID, "graveyard_id": g.ID, "corpse": pr.State.Corpse}},
+	}
+	nonBlockingSendJSON(pr.Client.Send, map[string]any{"type": "player_update", "player": pr.State})
+	return events, nil
+}
+
+func (s *Service) reclaimLocked(z *zone, pr *playerRuntime) ([]zoneEvent, error) {
+	switch {
+	case !pr.State.Ghost:
+		return nil, fmt.Errorf("you have not released your spirit")
+	case distance(pr.State.X, pr.State.Y, pr.State.Corpse.X, pr.State.Corpse.Y) > s.death.ReclaimRange:
+		return nil, fmt.Errorf("too far from your corpse")
+	}
+	return z.reviveLocked(pr, pr.State.Corpse.X, pr.State.Corpse.Y, s.death.ReleaseHPPercent, map[string]any{"method": "corpse"}), nil
+}
+
+func (s *Service) resurrectLocked(z *zone, pr *playerRuntime, targetID string) ([]zoneEvent, error) {
+	id, err := uuid.Parse(targetID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid target")
+	}
+	target, ok := z.players[id]
+	switch {
+	case !s.death.Resurrection:
+		return nil, fmt.Errorf("resurrection is disabled")
+	case pr.State.Dead:
+		return nil, fmt.Errorf("you are dead")
+	case pr.Mods.Stunned:
+		return nil, fmt.Errorf("you are stunned")
+	case !ok || !target.State.Dead:
+		return nil, fmt.Errorf("target is not dead")
+	case distance(pr.State.X, pr.State.Y, target.State.Corpse.X, target.State.Corpse.Y) > s.death.ResurrectRange:
+		return nil, fmt.Errorf("target out of range")
+	}
+	target.Resurrect = &resurrectOffer{FromID: pr.State.ID, FromName: pr.State.Name, ExpiresTick: z.tick + uint64(s.death.ResurrectOfferTicks)}
+	nonBlockingSendJSON(target.Client.Send, map[string]any{
+		"type":             "resurrect_offer",
+		"from_id":          pr.State.ID,
+		"from_name":        pr.State.Name,
+		"expires_in_ticks": s.death.ResurrectOfferTicks,
+	})
+	return standUpLocked(pr), nil
+}
+
+func (s *Service) acceptResurrectLocked(z *zone, pr *playerRuntime) ([]zoneEvent, error) {
+	offer := pr.Resurrect
+	if !pr.State.Dead || offer == nil || z.tick > offer.ExpiresTick {
+		pr.Resurrect = nil
+		return nil, fmt.Errorf("no resurrection offered")
+	}
+	return z.reviveLocked(pr, pr.State.Corpse.X, pr.State.Corpse.Y, s.death.ResurrectHPPercent, map[string]any{"method": "resurrection", "by": offer.FromID}), nil
+}
+
+// deathCommand runs a death command for the client's player and saves
+// them if it changed anything.
+func (s *Service) deathCommand(c *Client, fn func(z *zone, pr *playerRuntime) ([]zoneEvent, error)) {
+	z := s.zoneOf(c.CharacterID)
+	if z == nil {
+		return
+	}
+	z.mu.Lock()
+	pr, ok := z.players[c.CharacterID]
+	if !ok {
+		z.mu.Unlock()
+		return
+	}
+	events, err := fn(z, pr)
+	var save playerSave
+	if err == nil {
+		save = snapshotPlayerLocked(pr)
+	}
+	z.mu.Unlock()
+
+	if err != nil {
+		nonBlockingSendJSON(c.Send, map[string]any{"type": "error", "message": err.Error()})
+		return
+	}
+	z.dispatch(events)
+	s.savePlayerAsync(c, save)
+}
+
+// Release gives up on being resurrected: with corpse runs the player becomes
+// a ghost at the nearest graveyard, otherwise (or when already a ghost) they
+// respawn there and pay the death penalty.
+func (s *Service) Release(c *Client) {
+	s.deathCommand(c, func(z *zone, pr *playerRuntime) ([]zoneEvent, error) {
+		return s.releaseLocked(z, pr)
+	})
+}
+
+// Reclaim revives a ghost at its corpse without penalty.
+func (s *Service) Reclaim(c *Client) {
+	s.deathCommand(c, func(z *zone, pr *playerRuntime) ([]zoneEvent, error) {
+		return s.reclaimLocked(z, pr)
+	})
+}
+
+// Resurrect offers a dead player near their corpse a resurrection.
+func (s *Service) Resurrect(c *Client, targetID string) {
+	s.deathCommand(c, func(z *zone, pr *playerRuntime) ([]zoneEvent, error) {
+		return s.resurrectLocked(z, pr, targetID)
+	})
+}
+
+// AcceptResurrect takes a pending resurrection offer, reviving the player at
+// their corpse without penalty.
+func (s *Service) AcceptResurrect(c *Client) {
+	s.deathCommand(c, func(z *zone, pr *playerRuntime) ([]zoneEvent, error) {
+		return s.acceptResurrectLocked(z, pr)
+	})
+}
@@ -0,0 +1,166 @@
+package world
+
+import (
+	"fmt"
+
+	domainworld "mmorp-server/internal/domain/world"
+)
+
+// dialogueSession is the node a player is currently looking at in an NPC's
+// dialogue graph.
+type dialogueSession struct {
+	NPCID      string
+	DialogueID string
+	NodeID     string
+}
+
+func dialogueConditionMet(pr *playerRuntime, cond domainworld.DialogueCondition, quests map[string]domainworld.Quest) bool {
+	switch cond.Type {
+	case domainworld.DialogueConditionMinLevel:
+		return pr.State.Level >= cond.Value
+	case domainworld.DialogueConditionMaxLevel:
+		return pr.State.Level <= cond.Value
+	case domainworld.DialogueConditionMinGold:
+		return pr.State.Gold >= cond.Value
+	case domainworld.DialogueConditionQuestStatus:
+		return questStatus(pr, quests[cond.QuestID]) == cond.Status
+	}
+	return false
+}
+
+func (s *Service) dialogueChoiceAvailable(pr *playerRuntime, ch domainworld.DialogueChoice) bool {
+	for _, cond := range ch.Conditions {
+		if !dialogueConditionMet(pr, cond, s.quests) {
+			return false
+		}
+	}
+	return true
+}
+
+// dialogueNodeView lists only the choices whose conditions the player meets.
+// Each keeps its index in the node so the client can send it back.
+func (s *Service) dialogueNodeView(pr *playerRuntime, nodeID string, node domainworld.DialogueNode) map[string]any {
+	choices := make([]map[string]any, 0, len(node.Choices))
+	for i, ch := range node.Choices {
+		if s.dialogueChoiceAvailable(pr, ch) {
+			choices = append(choices, map[string]any{"index": i, "text": ch.Text})
+		}
+	}
+	return map[string]any{"id": nodeID, "text": node.Text, "choices": choices}
+}
+
+// startDialogueLocked opens the NPC's dialogue graph at its start node,
+// replacing any conversation the player had open.
+func (s *Service) startDialogueLocked(pr *playerRuntime, npc *domainworld.NPC) (map[string]any, bool) {
+	d, ok := s.dialogues[npc.DialogueID]
+	if !ok {
+		return nil, false
+	}
+	pr.Dialogue = &dialogueSession{NPCID: npc.ID, DialogueID: d.ID, NodeID: d.Start}
+	return s.dialogueNodeView(pr, d.Start, d.Nodes[d.Start]), true
+}
+
+func (s *Service) DialogueChoice(c *Client, npcID string, index int) {
+	z := s.zoneOf(c.CharacterID)
+	if z == nil {
+		return
+	}
+	z.mu.Lock()
+	pr, ok := z.players[c.CharacterID]
+	if !ok {
+		z.mu.Unlock()
+		return
+	}
+	msgs, save, err := s.dialogueChoiceLocked(z, pr, npcID, index)
+	z.mu.Unlock()
+
+	if err != nil {
+		nonBlockingSendJSON(c.Send, map[string]any{"type": "error", "message": err.Error()})
+	}
+	for _, msg := range msgs {
+		nonBlockingSendJSON(c.Send, msg)
+	}
+	if save != nil {
+		s.savePlayerAsync(c, *save)
+	}
+}
+
+// dialogueChoiceLocked runs the actions of the chosen option in order and
+// advances the session. An action that fails leaves the player on the
+// current node; the actions before it stay done and are saved.
+func (s *Service) dialogueChoiceLocked(z *zone, pr *playerRuntime, npcID string, index int) ([]map[string]any, *playerSave, error) {
+	if pr.State.Dead {
+		return nil, nil, fmt.Errorf("you are dead")
+	}
+	session := pr.Dialogue
+	if session == nil || session.NPCID != npcID {
+		return nil, nil, fmt.Errorf("not talking to that NPC")
+	}
+	npc := z.findNPC(npcID)
+	if npc == nil {
+		pr.Dialogue = nil
+		return nil, nil, fmt.Errorf("NPC not found")
+	}
+	if !inNPCRange(pr, npc) {
+		pr.Dialogue = nil
+		return nil, nil, fmt.Errorf("too far from %s", npc.Name)
+	}
+	d := s.dialogues[session.DialogueID]
+	node := d.Nodes[session.NodeID]
+	if index < 0 || index >= len(node.Choices) || !s.dialogueChoiceAvailable(pr, node.Choices[index]) {
+		return nil, nil, fmt.Errorf("invalid dialogue choice")
+	}
+	choice := node.Choices[index]
+
+	msgs := make([]map[string]any, 0, 4)
+	changed, questsChanged := false, false
+	var err error
+	for _, a := range choice.Actions {
+		var result map[string]any
+		switch a.Type {
+		case domainworld.DialogueActionOpenShop:
+			if !contains(npc.Interactions, string(domainworld.InteractionTypeTrade)) {
+				err = fmt.Errorf("%s has nothing to sell", npc.Name)
+				break
+			}
+			msgs = append(msgs, map[string]any{
+				"type":   "npc_response",
+				"npcId":  npc.ID,
+				"action": string(domainworld.InteractionTypeTrade),
+				"result": map[string]any{"stock": npc.Stock, "sell_prices": s.sellPrices(pr.Inventory)},
+			})
+		case domainworld.DialogueActionGrantQuest:
+			result, err = s.acceptQuestLocked(pr, npc, a.QuestID)
+			questsChanged = questsChanged || err == nil
+		case domainworld.DialogueActionTurnInQuest:
+			result, err = s.turnInQuestLocked(pr, npc, a.QuestID)
+			changed = changed || err == nil
+			questsChanged = questsChanged || err == nil
+		}
+		if err != nil {
+			break
+		}
+		if result != nil {
+			msgs = append(msgs, map[string]any{"type": "npc_response", "npcId": npc.ID, "action": string(a.Type), "result": result})
+		}
+	}
+
+	switch {
+	case err != nil:
+	case choice.Next == "":
+		pr.Dialogue = nil
+		msgs = append(msgs, map[string]any{"type": "dialogue_end", "npcId": npc.ID})
+	default:
+		session.NodeID = choice.Next
+		msgs = append(msgs, map[string]any{"type": "dialogue", "npcId": npc.ID, "node": s.dialogueNodeView(pr, choice.Next, d.Nodes[choice.Next])})
+	}
+	if changed {
+		msgs = append(msgs, map[string]any{"type": "player_update", "player": pr.State}, inventoryMessage(pr))
+	}
+	if !questsChanged {
+		return msgs, nil, err
+	}
+	msgs = append(msgs, s.questLogMessage(pr))
+	save := snapshotPlayerLocked(pr)
+	return msgs, &save, err
+}
@@ -0,0 +1,278 @@
+package world
+
+import (
+	"slices"
+
+	"github.com/google/uuid"
+
+	domainworld "mmorp-server/internal/domain/world"
+)
+
+// effectModifiers is what an entity's active effects add up to. It is
+// rebuilt whenever the effects change.
+type effectModifiers struct {
+	Stats   domainworld.Stats
+	Stunned bool
+	// Slow is the fraction of move speed lost; 1 when rooted.
+	Slow float64
+}
+
+func (m effectModifiers) speed(base float64) float64 {
+	if m.Stunned {
+		return 0
+	}
+	return base * (1 - m.Slow)
+}
+
+func (z *zone) effectModifiers(effects []domainworld.ActiveEffect) effectModifiers {
+	var m effectModifiers
+	for _, e := range effects {
+		def := z.effects[e.ID]
+		switch def.Kind {
+		case domainworld.EffectKindStat:
+			m.Stats = m.Stats.Add(def.Stats.Scale(e.Stacks))
+		case domainworld.EffectKindStun:
+			m.Stunned = true
+		case domainworld.EffectKindRoot:
+			m.Slow = 1
+		case domainworld.EffectKindSlow:
+			m.Slow = max(m.Slow, def.Slow)
+		}
+	}
+	return m
+}
+
+// withEffect returns a copy of effects with one more application of def,
+// following its stacking rule, and the entry that was added or updated. It
+// reports false when the rule leaves the effects unchanged.
+func withEffect(effects []domainworld.ActiveEffect, def domainworld.StatusEffect, sourceID string, tick uint64) ([]domainworld.ActiveEffect, domainworld.ActiveEffect, bool) {
+	expires := tick + uint64(def.DurationTicks)
+	next := slices.Clone(effects)
+	for i := range next {
+		e := &next[i]
+		if e.ID != def.ID {
+			continue
+		}
+		switch def.Stacking {
+		case domainworld.EffectStackingIgnore:
+			return effects, *e, false
+		case domainworld.EffectStackingStack:
+			e.Stacks = min(e.Stacks+1, def.MaxStacks)
+		}
+		e.ExpiresTick = expires
+		e.SourceID = sourceID
+		return next, *e, true
+	}
+	e := domainworld.ActiveEffect{
+		ID:          def.ID,
+		Kind:        def.Kind,
+		Stacks:      1,
+		ExpiresTick: expires,
+		SourceID:    sourceID,
+		NextTick:    tick + uint64(def.IntervalTicks),
+	}
+	return append(next, e), e, true
+}
+
+// rebaseEffects moves effect timings from one zone's tick counter to
+// another's, keeping the time each effect has left.
+func rebaseEffects(effects []domainworld.ActiveEffect, fromTick, toTick uint64) []domainworld.ActiveEffect {
+	if len(effects) == 0 {
+		return effects
+	}
+	next := make([]domainworld.ActiveEffect, len(effects))
+	for i, e := range effects {
+		e.ExpiresTick = e.ExpiresTick - fromTick + toTick
+		e.NextTick = e.NextTick - fromTick + toTick
+		next[i] = e
+	}
+	return next
+}
+
+// setModifiers swaps the player's effect modifiers, adjusting derived stats
+// without recomputing them from class and gear.
+func (pr *playerRuntime) setModifiers(m effectModifiers) {
+	pr.State.Stats = pr.State.Stats.Add(pr.Mods.Stats.Scale(-1)).Add(m.Stats)
+	pr.State.MaxHP = pr.State.Stats.MaxHP
+	pr.State.HP = min(pr.State.HP, pr.State.MaxHP)
+	pr.Mods = m
+}
+
+func (pr *playerRuntime) clearEffects() {
+	pr.State.Effects = nil
+	pr.setModifiers(effectModifiers{})
+}
+
+func (mob *mobRuntime) clearEffects() {
+	mob.State.Effects = nil
+	mob.Mods = effectModifiers{}
+}
+
+func (mob *mobRuntime) moveSpeed() float64 {
+	return mob.Mods.speed(mob.Template.MoveSpeed)
+}
+
+func effectAppliedEvent(x, y float64, targetID string, e domainworld.ActiveEffect) zoneEvent {
+	return zoneEvent{X: x, Y: y, Payload: map[string]any{"type": "effect_applied", "target_id": targetID, "effect": e}}
+}
+
+func effectRemovedEvent(x, y float64, targetID string, e domainworld.ActiveEffect) zoneEvent {
+	return zoneEvent{X: x, Y: y, Payload: map[string]any{"type": "effect_removed", "target_id": targetID, "effect_id": e.ID}}
+}
+
+// applyPlayerEffectLocked applies an effect to a living player and sends
+// them their updated state.
+func (z *zone) applyPlayerEffectLocked(pr *playerRuntime, effectID, sourceID string) []zoneEvent {
+	def, ok := z.effects[effectID]
+	if !ok || pr.State.HP <= 0 {
+		return nil
+	}
+	effects, applied, changed := withEffect(pr.State.Effects, def, sourceID, z.tick)
+	if !changed {
+		return nil
+	}
+	pr.State.Effects = effects
+	pr.setModifiers(z.effectModifiers(effects))
+	nonBlockingSendJSON(pr.Client.Send, map[string]any{"type": "player_update", "player": pr.State})
+	return []zoneEvent{effectAppliedEvent(pr.State.X, pr.State.Y, pr.State.ID.String(), applied)}
+}
+
+// applyMobEffectLocked applies an effect to a living mob that is not
+// evading.
+func (z *zone) applyMobEffectLocked(mob *mobRuntime, effectID, sourceID string) []zoneEvent {
+	def, ok := z.effects[effectID]
+	if !ok || !mob.State.Alive || mob.State.AIState == domainworld.MobAIStateEvade {
+		return nil
+	}
+	effects, applied, changed := withEffect(mob.State.Effects, def, sourceID, z.tick)
+	if !changed {
+		return nil
+	}
+	mob.State.Effects = effects
+	mob.Mods = z.effectModifiers(effects)
+	return []zoneEvent{effectAppliedEvent(mob.State.X, mob.State.Y, mob.State.ID, applied)}
+}
+
+// effectPulse is one damage or healing tick of an effect over time.
+type effectPulse struct {
+	Effect domainworld.ActiveEffect
+	Amount int
+}
+
+// tickEffectsLocked advances effects by one tick. It returns the effects
+// still running, the pulses due this tick and the effects that ran out.
+func (z *zone) tickEffectsLocked(effects []domainworld.ActiveEffect) ([]domainworld.ActiveEffect, []effectPulse, []domainworld.ActiveEffect) {
+	next := make([]domainworld.ActiveEffect, 0, len(effects))
+	var pulses []effectPulse
+	var expired []domainworld.ActiveEffect
+	for _, e := range effects {
+		def := z.effects[e.ID]
+		overTime := def.Kind == domainworld.EffectKindDoT || def.Kind == domainworld.EffectKindHoT
+		if overTime && z.tick >= e.NextTick && e.NextTick <= e.ExpiresTick {
+			pulses = append(pulses, effectPulse{Effect: e, Amount: def.Amount * e.Stacks})
+			e.NextTick += uint64(def.IntervalTicks)
+		}
+		if z.tick >= e.ExpiresTick {
+			expired = append(expired, e)
+			continue
+		}
+		next = append(next, e)
+	}
+	return next, pulses, expired
+}
+
+func effectTickPayload(targetID string, p effectPulse, hp int) map[string]any {
+	payload := map[string]any{"type": "effect_tick", "target_id": targetID, "effect_id": p.Effect.ID, "source_id": p.Effect.SourceID, "hp": hp}
+	if p.Effect.Kind == domainworld.EffectKindHoT {
+		payload["heal"] = p.Amount
+	} else {
+		payload["damage"] = p.Amount
+	}
+	return payload
+}
+
+// stepEffectsLocked deals damage and healing over time and removes expired
+// effects. Damage over time on a mob counts as its source player's damage,
+// so it draws threat and a kill is credited to them.
+func (s *Service) stepEffectsLocked(z *zone) []zoneEvent {
+	events := make([]zoneEvent, 0)
+	for _, pr := range z.players {
+		if len(pr.State.Effects) == 0 {
+			continue
+		}
+		next, pulses, expired := z.tickEffectsLocked(pr.State.Effects)
+		if len(pulses) == 0 && len(expired) == 0 {
+			continue
+		}
+		pr.State.Effects = next
+		pr.setModifiers(z.effectModifiers(next))
+		id := pr.State.ID.String()
+		for _, e := range expired {
+			events = append(events, effectRemovedEvent(pr.State.X, pr.State.Y, id, e))
+		}
+		for _, p := range pulses {
+			if pr.State.HP <= 0 {
+				break
+			}
+			if p.Effect.Kind == domainworld.EffectKindHoT {
+				p.Amount = min(p.Amount, pr.State.MaxHP-pr.State.HP)
+				pr.State.HP += p.Amount
+				if healerID, err := uuid.Parse(p.Effect.SourceID); err == nil {
+					z.addHealThreatLocked(healerID, pr.State.ID, p.Amount)
+				}
+				events = append(events, zoneEvent{X: pr.State.X, Y: pr.State.Y, Payload: effectTickPayload(id, p, pr.State.HP)})
+				continue
+			}
+			pr.State.HP -= p.Amount
+			events = append(events, zoneEvent{X: pr.State.X, Y: pr.State.Y, Payload: effectTickPayload(id, p, max(pr.State.HP, 0))})
+			events = append(events, z.enterCombatLocked(pr)...)
+			if pr.State.HP <= 0 {
+				events = append(events, z.killPlayerLocked(pr)...)
+			}
+		}
+		nonBlockingSendJSON(pr.Client.Send, map[string]any{"type": "player_update", "player": pr.State})
+	}
+
+	for _, mob := range z.mobs {
+		if len(mob.State.Effects) == 0 {
+			continue
+		}
+		next, pulses, expired := z.tickEffectsLocked(mob.State.Effects)
+		if len(pulses) == 0 && len(expired) == 0 {
+			continue
+		}
+		mob.State.Effects = next
+		mob.Mods = z.effectModifiers(next)
+		for _, e := range expired {
+			events = append(events, effectRemovedEvent(mob.State.X, mob.State.Y, mob.State.ID, e))
+		}
+		for _, p := range pulses {
+			if !mob.State.Alive {
+				break
+			}
+			x, y := mob.State.X, mob.State.Y
+			if p.Effect.Kind == domainworld.EffectKindHoT {
+				p.Amount = min(p.Amount, mob.State.MaxHP-mob.State.HP)
+				mob.State.HP += p.Amount
+				events = append(events, zoneEvent{X: x, Y: y, Payload: effectTickPayload(mob.State.ID, p, mob.State.HP)})
+				continue
+			}
+			events = append(events, zoneEvent{X: x, Y: y, Payload: effectTickPayload(mob.State.ID, p, max(mob.State.HP-p.Amount, 0))})
+			sourceID, _ := uuid.Parse(p.Effect.SourceID)
+			if source, ok := z.players[sourceID]; ok {
+				killEvents, self := s.damageMobLocked(z, source, mob, p.Amount)
+				events = append(events, killEvents...)
+				for _, msg := range self {
+					nonBlockingSendJSON(source.Client.Send, msg)
+				}
+				continue
+			}
+			mob.State.HP -= p.Amount
+			if mob.State.HP <= 0 {
+				mob.die()
+				events = append(events, zoneEvent{X: x, Y: y, Payload: map[string]any{"type": "mob_died", "mob_id": mob.State.ID}})
+			}
+		}
+	}
+	return events
+}
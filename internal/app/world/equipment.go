@@ -0,0 +1,194 @@
+package world
+
+import (
+	"fmt"
+	"math"
+
+	"mmorp-server/internal/domain/character"
+	domainworld "mmorp-server/internal/domain/world"
+)
+
+const armorMitigationFactor = 50.0
+
+// deriveStats adds class stats at the given level to those of every
+// equipped item that is not broken.
+func deriveStats(cls character.Class, level int, equipment map[domainworld.EquipSlot]string, wear map[domainworld.EquipSlot]int, items map[string]domainworld.Item) domainworld.Stats {
+	stats := cls.StatsAt(level)
+	for slot, id := range equipment {
+		if broken(items[id], wear[slot]) {
+			continue
+		}
+		stats = stats.Add(items[id].Stats)
+	}
+	return stats
+}
+
+func broken(item domainworld.Item, wear int) bool {
+	return item.Durability > 0 && wear >= item.Durability
+}
+
+// refreshStats recomputes derived stats after a level or gear change and
+// keeps HP within the new maximum. Active effect modifiers are included.
+func refreshStats(pr *playerRuntime, items map[string]domainworld.Item) {
+	pr.State.Stats = deriveStats(pr.Class, pr.State.Level, pr.State.Equipment, pr.State.Wear, items).Add(pr.Mods.Stats)
+	pr.State.MaxHP = pr.State.Stats.MaxHP
+	pr.State.HP = min(pr.State.HP, pr.State.MaxHP)
+	pr.State.ResourceType = pr.Class.Resource
+	pr.State.MaxResource = pr.Class.MaxResourceAt(pr.State.Level)
+	pr.State.Resource = min(pr.State.Resource, pr.State.MaxResource)
+}
+
+func mitigateDamage(dmg, armor int) int {
+	if armor <= 0 {
+		return dmg
+	}
+	reduced := int(math.Round(float64(dmg) * armorMitigationFactor / (armorMitigationFactor + float64(armor))))
+	return max(1, reduced)
+}
+
+func restoreEquipment(saved []character.EquippedItem, items map[string]domainworld.Item) (map[domainworld.EquipSlot]string, map[domainworld.EquipSlot]int) {
+	equipment := make(map[domainworld.EquipSlot]string, len(saved))
+	var wear map[domainworld.EquipSlot]int
+	for _, e := range saved {
+		item, ok := items[e.ItemID]
+		if !ok || string(item.Slot) != e.Slot {
+			continue
+		}
+		equipment[item.Slot] = item.ID
+		if e.Wear > 0 && item.Durability > 0 {
+			if wear == nil {
+				wear = make(map[domainworld.EquipSlot]int)
+			}
+			wear[item.Slot] = min(e.Wear, item.Durability)
+		}
+	}
+	return equipment, wear
+}
+
+func equippedItems(equipment map[domainworld.EquipSlot]string, wear map[domainworld.EquipSlot]int) []character.EquippedItem {
+	out := make([]character.EquippedItem, 0, len(equipment))
+	for slot, id := range equipment {
+		out = append(out, character.EquippedItem{Slot: string(slot), ItemID: id, Wear: wear[slot]})
+	}
+	return out
+}
+
+func withEquipped(equipment map[domainworld.EquipSlot]string, slot domainworld.EquipSlot, itemID string) map[domainworld.EquipSlot]string {
+	next := make(map[domainworld.EquipSlot]string, len(equipment)+1)
+	for k, v := range equipment {
+		next[k] = v
+	}
+	if itemID == "" {
+		delete(next, slot)
+	} else {
+		next[slot] = itemID
+	}
+	return next
+}
+
+// withWear returns a copy of wear with slot set, dropping slots without wear;
+// the result is nil when nothing is worn.
+func withWear(wear map[domainworld.EquipSlot]int, slot domainworld.EquipSlot, n int) map[domainworld.EquipSlot]int {
+	next := make(map[domainworld.EquipSlot]int, len(wear)+1)
+	for k, v := range wear {
+		next[k] = v
+	}
+	if n > 0 {
+		next[slot] = n
+	} else {
+		delete(next, slot)
+	}
+	if len(next) == 0 {
+		return nil
+	}
+	return next
+}
+
+// Equip moves the item in an inventory slot into its equipment slot; whatever
+// was equipped there goes back into the inventory. Wear moves with the item
+// both ways. Like every inventory change, the result is saved at once.
+func (s *Service) Equip(c *Client, invSlot int) {
+	s.withInventory(c, func(z *zone, pr *playerRuntime) ([]zoneEvent, error) {
+		if pr.State.Dead {
+			return nil, fmt.Errorf("you are dead")
+		}
+		if invSlot < 0 || invSlot >= len(pr.Inventory.slots) || pr.Inventory.slots[invSlot].ItemID == "" {
+			return nil, fmt.Errorf("inventory slot is empty")
+		}
+		item := s.items[pr.Inventory.slots[invSlot].ItemID]
+		if item.Slot == "" {
+			return nil, fmt.Errorf("%s cannot be equipped", item.Name)
+		}
+		prev := pr.State.Equipment[item.Slot]
+		taken, err := pr.Inventory.Take(invSlot, 1)
+		if err != nil {
+			return nil, err
+		}
+		if prev != "" && !pr.Inventory.Store(prev, pr.State.Wear[item.Slot]) {
+			pr.Inventory.Store(item.ID, taken.Wear)
+			return nil, fmt.Errorf("inventory full")
+		}
+		pr.State.Equipment = withEquipped(pr.State.Equipment, item.Slot, item.ID)
+		pr.State.Wear = withWear(pr.State.Wear, item.Slot, taken.Wear)
+		refreshStats(pr, s.items)
+		nonBlockingSendJSON(c.Send, map[string]any{"type": "player_update", "player": pr.State})
+		return nil, nil
+	})
+}
+
+func (s *Service) Unequip(c *Client, slot domainworld.EquipSlot) {
+	s.withInventory(c, func(z *zone, pr *playerRuntime) ([]zoneEvent, error) {
+		if pr.State.Dead {
+			return nil, fmt.Errorf("you are dead")
+		}
+		itemID, ok := pr.State.Equipment[slot]
+		if !ok {
+			return nil, fmt.Errorf("nothing equipped in %s", slot)
+		}
+		if !pr.Inventory.Store(itemID, pr.State.Wear[slot]) {
+			return nil, fmt.Errorf("inventory full")
+		}
+		pr.State.Equipment = withEquipped(pr.State.Equipment, slot, "")
+		pr.State.Wear = withWear(pr.State.Wear, slot, 0)
+		refreshStats(pr, s.items)
+		nonBlockingSendJSON(c.Send, map[string]any{"type": "player_update", "player": pr.State})
+		return nil, nil
+	})
+}
+
+// repairCost is the item's value scaled by the share of durability lost,
+// rounded up.
+func repairCost(item domainworld.Item, wear int) int {
+	if item.Durability <= 0 {
+		return 0
+	}
+	return (item.Value*wear + item.Durability - 1) / item.Durability
+}
+
+// repairLocked mends equipped gear and worn items in the bags.
+func (s *Service) repairLocked(pr *playerRuntime) (map[string]any, error) {
+	cost, worn := 0, false
+	for slot, wear := range pr.State.Wear {
+		cost += repairCost(s.items[pr.State.Equipment[slot]], wear)
+		worn = true
+	}
+	for _, slot := range pr.Inventory.slots {
+		if slot.Wear > 0 {
+			cost += repairCost(s.items[slot.ItemID], slot.Wear)
+			worn = true
+		}
+	}
+	if !worn {
+		return nil, fmt.Errorf("nothing needs repair")
+	}
+	if pr.State.Gold < cost {
+		return nil, fmt.Errorf("not enough gold (need %d)", cost)
+	}
+	pr.State.Gold -= cost
+	pr.State.Wear = nil
+	for i := range pr.Inventory.slots {
+		pr.Inventory.slots[i].Wear = 0
+	}
+	refreshStats(pr, s.items)
+	return map[string]any{"success": true, "gold_spent": cost}, nil
+}
@@ -0,0 +1,183 @@
+package world
+
+import (
+	"math"
+
+	"github.com/google/uuid"
+)
+
+const (
+	interestCellSize = 8.0
+	viewRadius       = 12.0
+)
+
+type gridCell struct {
+	X int
+	Y int
+}
+
+type spatialGrid[K comparable] struct {
+	cellSize float64
+	cells    map[gridCell]map[K]struct{}
+	index    map[K]gridCell
+}
+
+func newSpatialGrid[K comparable](cellSize float64) *spatialGrid[K] {
+	return &spatialGrid[K]{
+		cellSize: cellSize,
+		cells:    make(map[gridCell]map[K]struct{}),
+		index:    make(map[K]gridCell),
+	}
+}
+
+func (g *spatialGrid[K]) cellAt(x, y float64) gridCell {
+	return gridCell{X: int(math.Floor(x / g.cellSize)), Y: int(math.Floor(y / g.cellSize))}
+}
+
+func (g *spatialGrid[K]) Upsert(id K, x, y float64) {
+	cell := g.cellAt(x, y)
+	if prev, ok := g.index[id]; ok {
+		if prev == cell {
+			return
+		}
+		g.removeFromCell(id, prev)
+	}
+	members, ok := g.cells[cell]
+	if !ok {
+		members = make(map[K]struct{})
+		g.cells[cell] = members
+	}
+	members[id] = struct{}{}
+	g.index[id] = cell
+}
+
+func (g *spatialGrid[K]) Remove(id K) {
+	cell, ok := g.index[id]
+	if !ok {
+		return
+	}
+	g.removeFromCell(id, cell)
+	delete(g.index, id)
+}
+
+func (g *spatialGrid[K]) removeFromCell(id K, cell gridCell) {
+	members := g.cells[cell]
+	delete(members, id)
+	if len(members) == 0 {
+		delete(g.cells, cell)
+	}
+}
+
+// Query visits every entry in the cells overlapping the square around (x, y).
+// Callers still need an exact distance check.
+func (g *spatialGrid[K]) Query(x, y, radius float64, fn func(K)) {
+	minCell := g.cellAt(x-radius, y-radius)
+	maxCell := g.cellAt(x+radius, y+radius)
+	for cy := minCell.Y; cy <= maxCell.Y; cy++ {
+		for cx := minCell.X; cx <= maxCell.X; cx++ {
+			for id := range g.cells[gridCell{X: cx, Y: cy}] {
+				fn(id)
+			}
+		}
+	}
+}
+
+func (z *zone) playersNearLocked(x, y, radius float64) []*playerRuntime {
+	near := make([]*playerRuntime, 0)
+	z.playerGrid.Query(x, y, radius, func(id uuid.UUID) {
+		p, ok := z.players[id]
+		if ok && distance(x, y, p.State.X, p.State.Y) <= radius {
+			near = append(near, p)
+		}
+	})
+	return near
+}
+
+func (z *zone) mobsNearLocked(x, y, radius float64) []*mobRuntime {
+	near := make([]*mobRuntime, 0)
+	z.mobGrid.Query(x, y, radius, func(id string) {
+		m, ok := z.mobs[id]
+		if ok && distance(x, y, m.State.X, m.State.Y) <= radius {
+			near = append(near, m)
+		}
+	})
+	return near
+}
+
+func (z *zone) addPlayerLocked(pr *playerRuntime) []*playerRuntime {
+	z.players[pr.State.ID] = pr
+	z.playerGrid.Upsert(pr.State.ID, pr.State.X, pr.State.Y)
+	pr.VisiblePlayers = make(map[uuid.UUID]struct{})
+	pr.VisibleMobs = make(map[string]struct{})
+	pr.MobBaseline = nil
+
+	observers := make([]*playerRuntime, 0)
+	for _, o := range z.playersNearLocked(pr.State.X, pr.State.Y, viewRadius) {
+		if o == pr {
+			continue
+		}
+		pr.VisiblePlayers[o.State.ID] = struct{}{}
+		o.VisiblePlayers[pr.State.ID] = struct{}{}
+		observers = append(observers, o)
+	}
+	for _, m := range z.mobsNearLocked(pr.State.X, pr.State.Y, viewRadius) {
+		pr.VisibleMobs[m.State.ID] = struct{}{}
+	}
+	return observers
+}
+
+func (z *zone) removePlayerLocked(id uuid.UUID) (*playerRuntime, []*playerRuntime) {
+	pr, ok := z.players[id]
+	if !ok {
+		return nil, nil
+	}
+	delete(z.players, id)
+	z.playerGrid.Remove(id)
+
+	observers := make([]*playerRuntime, 0, len(pr.VisiblePlayers))
+	for oid := range pr.VisiblePlayers {
+		o, ok := z.players[oid]
+		if !ok {
+			continue
+		}
+		delete(o.VisiblePlayers, id)
+		observers = append(observers, o)
+	}
+	return pr, observers
+}
+
+func (z *zone) refreshInterestLocked(pr *playerRuntime) []any {
+	events := make([]any, 0)
+
+	players := make(map[uuid.UUID]struct{})
+	for _, o := range z.playersNearLocked(pr.State.X, pr.State.Y, viewRadius) {
+		if o == pr {
+			continue
+		}
+		players[o.State.ID] = struct{}{}
+		if _, seen := pr.VisiblePlayers[o.State.ID]; !seen {
+			events = append(events, map[string]any{"type": "entity_entered", "kind": "player", "entity": o.State})
+		}
+	}
+	for id := range pr.VisiblePlayers {
+		if _, still := players[id]; !still {
+			events = append(events, map[string]any{"type": "entity_left", "kind": "player", "id": id})
+		}
+	}
+	pr.VisiblePlayers = players
+
+	mobs := make(map[string]struct{})
+	for _, m := range z.mobsNearLocked(pr.State.X, pr.State.Y, viewRadius) {
+		mobs[m.State.ID] = struct{}{}
+		if _, seen := pr.VisibleMobs[m.State.ID]; !seen {
+			events = append(events, map[string]any{"type": "entity_entered", "kind": "mob", "entity": m.State})
+		}
+	}
+	for id := range pr.VisibleMobs {
+		if _, still := mobs[id]; !still {
+			events = append(events, map[string]any{"type": "entity_left", "kind": "mob", "id": id})
+		}
+	}
+	pr.VisibleMobs = mobs
+	return events
+}
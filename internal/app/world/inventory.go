@@ -0,0 +1,341 @@
+package world
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+
+	"mmorp-server/internal/domain/character"
+	domainworld "mmorp-server/internal/domain/world"
+)
+
+const lootPickupRange = 2.0
+
+type inventorySlot struct {
+	ItemID   string
+	Quantity int
+	Wear     int
+}
+
+// inventory is a fixed number of slots; a slot with an empty ItemID is free.
+// Stacks never exceed the item's MaxStack.
+type inventory struct {
+	items map[string]domainworld.Item
+	slots []inventorySlot
+}
+
+func newInventory(items map[string]domainworld.Item, capacity int, saved []character.InventorySlot) *inventory {
+	inv := &inventory{items: items, slots: make([]inventorySlot, capacity)}
+	for _, s := range saved {
+		item, ok := items[s.ItemID]
+		if !ok || s.Slot < 0 || s.Slot >= capacity || s.Quantity <= 0 || inv.slots[s.Slot].ItemID != "" {
+			continue
+		}
+		inv.slots[s.Slot] = inventorySlot{ItemID: s.ItemID, Quantity: min(s.Quantity, item.MaxStack), Wear: min(max(s.Wear, 0), item.Durability)}
+	}
+	return inv
+}
+
+func (inv *inventory) Slots() []character.InventorySlot {
+	out := make([]character.InventorySlot, 0, len(inv.slots))
+	for i, s := range inv.slots {
+		if s.ItemID == "" {
+			continue
+		}
+		out = append(out, character.InventorySlot{Slot: i, ItemID: s.ItemID, Quantity: s.Quantity, Wear: s.Wear})
+	}
+	return out
+}
+
+func (inv *inventory) payload() map[string]any {
+	return map[string]any{"capacity": len(inv.slots), "slots": inv.Slots()}
+}
+
+// Room reports how many more of itemID would fit.
+func (inv *inventory) Room(itemID string) int {
+	item, ok := inv.items[itemID]
+	if !ok {
+		return 0
+	}
+	room := 0
+	for _, s := range inv.slots {
+		switch s.ItemID {
+		case "":
+			room += item.MaxStack
+		case itemID:
+			room += item.MaxStack - s.Quantity
+		}
+	}
+	return room
+}
+
+func (inv *inventory) Count(itemID string) int {
+	n := 0
+	for _, s := range inv.slots {
+		if s.ItemID == itemID {
+			n += s.Quantity
+		}
+	}
+	return n
+}
+
+// Remove takes qty of itemID from wherever it is stacked. The caller must
+// have checked Count first.
+func (inv *inventory) Remove(itemID string, qty int) {
+	for i := range inv.slots {
+		if qty == 0 {
+			return
+		}
+		s := &inv.slots[i]
+		if s.ItemID != itemID {
+			continue
+		}
+		n := min(qty, s.Quantity)
+		s.Quantity -= n
+		qty -= n
+		if s.Quantity == 0 {
+			*s = inventorySlot{}
+		}
+	}
+}
+
+// Add stores up to qty of itemID, topping up existing stacks before using
+// free slots, and returns how many did not fit.
+func (inv *inventory) Add(itemID string, qty int) int {
+	item, ok := inv.items[itemID]
+	if !ok {
+		return qty
+	}
+	for i := range inv.slots {
+		if qty == 0 {
+			return 0
+		}
+		s := &inv.slots[i]
+		if s.ItemID != itemID || s.Quantity >= item.MaxStack {
+			continue
+		}
+		n := min(qty, item.MaxStack-s.Quantity)
+		s.Quantity += n
+		qty -= n
+	}
+	for i := range inv.slots {
+		if qty == 0 {
+			return 0
+		}
+		s := &inv.slots[i]
+		if s.ItemID != "" {
+			continue
+		}
+		n := min(qty, item.MaxStack)
+		*s = inventorySlot{ItemID: itemID, Quantity: n}
+		qty -= n
+	}
+	return qty
+}
+
+// Store puts a single item that has taken wear into a free slot, so it does
+// not stack with unworn ones, and reports whether it fit.
+func (inv *inventory) Store(itemID string, wear int) bool {
+	if wear <= 0 {
+		return inv.Add(itemID, 1) == 0
+	}
+	for i := range inv.slots {
+		if inv.slots[i].ItemID == "" {
+			inv.slots[i] = inventorySlot{ItemID: itemID, Quantity: 1, Wear: wear}
+			return true
+		}
+	}
+	return false
+}
+
+func (inv *inventory) Take(slot, qty int) (inventorySlot, error) {
+	if slot < 0 || slot >= len(inv.slots) || inv.slots[slot].ItemID == "" {
+		return inventorySlot{}, fmt.Errorf("inventory slot is empty")
+	}
+	s := &inv.slots[slot]
+	if qty <= 0 || qty > s.Quantity {
+		qty = s.Quantity
+	}
+	taken := inventorySlot{ItemID: s.ItemID, Quantity: qty, Wear: s.Wear}
+	s.Quantity -= qty
+	if s.Quantity == 0 {
+		*s = inventorySlot{}
+	}
+	return taken, nil
+}
+
+// Move puts the stack in from onto to: into an empty slot it moves, onto the
+// same item it merges up to MaxStack, and otherwise the two slots swap.
+func (inv *inventory) Move(from, to int) error {
+	if from < 0 || from >= len(inv.slots) || to < 0 || to >= len(inv.slots) {
+		return fmt.Errorf("invalid inventory slot")
+	}
+	src, dst := &inv.slots[from], &inv.slots[to]
+	if src.ItemID == "" {
+		return fmt.Errorf("inventory slot is empty")
+	}
+	if from == to {
+		return nil
+	}
+	if src.ItemID == dst.ItemID && src.Wear == dst.Wear {
+		n := min(src.Quantity, inv.items[src.ItemID].MaxStack-dst.Quantity)
+		dst.Quantity += n
+		src.Quantity -= n
+		if src.Quantity == 0 {
+			*src = inventorySlot{}
+		}
+		return nil
+	}
+	*src, *dst = *dst, *src
+	return nil
+}
+
+func (s *Service) ListInventory(c *Client) {
+	z := s.zoneOf(c.CharacterID)
+	if z == nil {
+		return
+	}
+	z.mu.RLock()
+	defer z.mu.RUnlock()
+	if pr, ok := z.players[c.CharacterID]; ok {
+		nonBlockingSendJSON(c.Send, inventoryMessage(pr))
+	}
+}
+
+func (s *Service) MoveItem(c *Client, from, to int) {
+	s.withInventory(c, func(z *zone, pr *playerRuntime) ([]zoneEvent, error) {
+		return nil, pr.Inventory.Move(from, to)
+	})
+}
+
+func (s *Service) DropItem(c *Client, slot, qty int) {
+	s.withInventory(c, func(z *zone, pr *playerRuntime) ([]zoneEvent, error) {
+		if pr.State.Dead {
+			return nil, fmt.Errorf("you are dead")
+		}
+		taken, err := pr.Inventory.Take(slot, qty)
+		if err != nil {
+			return nil, err
+		}
+		item := s.items[taken.ItemID]
+		loot := z.spawnLootLocked(pr.State.X, pr.State.Y, uuid.Nil, []domainworld.LootItem{{ItemID: item.ID, Quantity: taken.Quantity, Rarity: item.Rarity, Wear: taken.Wear}})
+		return []zoneEvent{{Global: true, Payload: map[string]any{"type": "loot_dropped", "player_id": pr.State.ID, "loot": loot.State}}}, nil
+	})
+}
+
+func (s *Service) UseItem(c *Client, slot int) {
+	s.withInventory(c, func(z *zone, pr *playerRuntime) ([]zoneEvent, error) {
+		if pr.State.Dead {
+			return nil, fmt.Errorf("you are dead")
+		}
+		if slot < 0 || slot >= len(pr.Inventory.slots) || pr.Inventory.slots[slot].ItemID == "" {
+			return nil, fmt.Errorf("inventory slot is empty")
+		}
+		item := s.items[pr.Inventory.slots[slot].ItemID]
+		if item.Kind != domainworld.ItemKindConsumable {
+			return nil, fmt.Errorf("%s cannot be used", item.Name)
+		}
+		if item.Heal > 0 && len(item.Effects) == 0 && pr.State.HP >= pr.State.MaxHP {
+			return nil, fmt.Errorf("already at full health")
+		}
+		if item.Food && z.inCombatLocked(pr) {
+			return nil, fmt.Errorf("you cannot eat in combat")
+		}
+		if _, err := pr.Inventory.Take(slot, 1); err != nil {
+			return nil, err
+		}
+		var events []zoneEvent
+		if item.Food {
+			if !pr.State.Resting {
+				pr.State.Resting = true
+				pr.PendingMove = nil
+				events = append(events, restingEvent(pr))
+			}
+			pr.Meal = &mealState{HealPerSecond: item.Heal, UntilTick: z.tick + mealTicks}
+		} else {
+			pr.State.HP = min(pr.State.MaxHP, pr.State.HP+item.Heal)
+		}
+		for _, id := range item.Effects {
+			events = append(events, z.applyPlayerEffectLocked(pr, id, pr.State.ID.String())...)
+		}
+		nonBlockingSendJSON(c.Send, map[string]any{"type": "item_used", "item_id": item.ID, "slot": slot})
+		nonBlockingSendJSON(c.Send, map[string]any{"type": "player_update", "player": pr.State})
+		return events, nil
+	})
+}
+
+func (s *Service) PickupLoot(c *Client, lootID string) {
+	s.withInventory(c, func(z *zone, pr *playerRuntime) ([]zoneEvent, error) {
+		if pr.State.Dead {
+			return nil, fmt.Errorf("you are dead")
+		}
+		loot, ok := z.loot[lootID]
+		if !ok {
+			return nil, fmt.Errorf("loot not found")
+		}
+		if loot.State.OwnerID != uuid.Nil && loot.State.OwnerID != pr.State.ID {
+			return nil, fmt.Errorf("loot belongs to someone else")
+		}
+		if distance(pr.State.X, pr.State.Y, loot.State.X, loot.State.Y) > lootPickupRange {
+			return nil, fmt.Errorf("loot out of range")
+		}
+		remaining := make([]domainworld.LootItem, 0)
+		for _, it := range loot.State.Items {
+			if it.Wear > 0 {
+				if !pr.Inventory.Store(it.ItemID, it.Wear) {
+					remaining = append(remaining, it)
+				}
+				continue
+			}
+			if left := pr.Inventory.Add(it.ItemID, it.Quantity); left > 0 {
+				it.Quantity = left
+				remaining = append(remaining, it)
+			}
+		}
+		loot.State.Items = remaining
+		if len(remaining) == 0 {
+			delete(z.loot, lootID)
+			return []zoneEvent{{Global: true, Payload: map[string]any{"type": "loot_taken", "loot_id": lootID, "player_id": pr.State.ID}}}, nil
+		}
+		return []zoneEvent{{Global: true, Payload: map[string]any{"type": "loot_updated", "loot": loot.State}}}, fmt.Errorf("inventory full")
+	})
+}
+
+// withInventory runs fn against the caller's inventory under the zone lock
+// and replies with the resulting inventory, plus the error fn returned. The
+// events fn returns are dispatched once the lock is released. The player is
+// saved whenever fn changed something: it succeeded or returned events, as
+// a partial loot pickup does.
+func (s *Service) withInventory(c *Client, fn func(z *zone, pr *playerRuntime) ([]zoneEvent, error)) {
+	z := s.zoneOf(c.CharacterID)
+	if z == nil {
+		return
+	}
+	z.mu.Lock()
+	pr, ok := z.players[c.CharacterID]
+	if !ok {
+		z.mu.Unlock()
+		return
+	}
+	events, err := fn(z, pr)
+	if err != nil {
+		nonBlockingSendJSON(c.Send, map[string]any{"type": "error", "message": err.Error()})
+	}
+	nonBlockingSendJSON(c.Send, inventoryMessage(pr))
+	changed := err == nil || len(events) > 0
+	var save playerSave
+	if changed {
+		save = snapshotPlayerLocked(pr)
+	}
+	z.mu.Unlock()
+	z.dispatch(events)
+	if changed {
+		s.savePlayerAsync(c, save)
+	}
+}
+
+func inventoryMessage(pr *playerRuntime) map[string]any {
+	msg := pr.Inventory.payload()
+	msg["type"] = "inventory"
+	return msg
+}
@@ -0,0 +1,148 @@
+package world
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+
+	domainworld "mmorp-server/internal/domain/world"
+)
+
+const groundLootTicks = 600
+
+type groundLoot struct {
+	State       domainworld.GroundLoot
+	ExpiresTick uint64
+}
+
+func normalizeLootTable(table domainworld.LootTable) (domainworld.LootTable, error) {
+	if table.GoldMax == 0 {
+		table.GoldMax = table.GoldMin
+	}
+	if table.GoldMin < 0 || table.GoldMax < table.GoldMin {
+		return table, fmt.Errorf("invalid gold range %d-%d", table.GoldMin, table.GoldMax)
+	}
+	if len(table.Drops) > 0 && table.Rolls <= 0 {
+		table.Rolls = 1
+	}
+	drops := make([]domainworld.LootDrop, 0, len(table.Drops))
+	for _, d := range table.Drops {
+		if d.Weight <= 0 {
+			return table, fmt.Errorf("drop %q needs a positive weight", d.ItemID)
+		}
+		if d.MinQuantity <= 0 {
+			d.MinQuantity = 1
+		}
+		if d.MaxQuantity < d.MinQuantity {
+			d.MaxQuantity = d.MinQuantity
+		}
+		if d.Rarity == "" {
+			d.Rarity = domainworld.ItemRarityCommon
+		}
+		if !d.Rarity.Valid() {
+			return table, fmt.Errorf("drop %q has unknown rarity %q", d.ItemID, d.Rarity)
+		}
+		drops = append(drops, d)
+	}
+	table.Drops = drops
+	return table, nil
+}
+
+// rollLootLocked rolls a loot table with the zone's RNG. Repeated picks of the
+// same item are merged into one stack.
+func (z *zone) rollLootLocked(table domainworld.LootTable) (int, []domainworld.LootItem) {
+	gold := table.GoldMin
+	if table.GoldMax > table.GoldMin {
+		gold += z.rand.Intn(table.GoldMax - table.GoldMin + 1)
+	}
+	total := 0
+	for _, d := range table.Drops {
+		total += d.Weight
+	}
+	items := make([]domainworld.LootItem, 0)
+	for i := 0; i < table.Rolls && total > 0; i++ {
+		pick := z.rand.Intn(total)
+		for _, d := range table.Drops {
+			if pick >= d.Weight {
+				pick -= d.Weight
+				continue
+			}
+			if d.ItemID == "" {
+				break
+			}
+			qty := d.MinQuantity
+			if d.MaxQuantity > d.MinQuantity {
+				qty += z.rand.Intn(d.MaxQuantity - d.MinQuantity + 1)
+			}
+			items = mergeLootItem(items, domainworld.LootItem{ItemID: d.ItemID, Quantity: qty, Rarity: d.Rarity})
+			break
+		}
+	}
+	return gold, items
+}
+
+func mergeLootItem(items []domainworld.LootItem, item domainworld.LootItem) []domainworld.LootItem {
+	for i := range items {
+		if items[i].ItemID == item.ItemID {
+			items[i].Quantity += item.Quantity
+			return items
+		}
+	}
+	return append(items, item)
+}
+
+// dropLootLocked awards the killer the mob's gold directly and leaves any
+// items on the ground, reserved for the killer until they expire.
+func (z *zone) dropLootLocked(mob *mobRuntime, killer *playerRuntime) zoneEvent {
+	gold, items := z.rollLootLocked(mob.Template.Loot)
+	killer.State.Gold += gold
+	payload := map[string]any{
+		"type":      "loot_dropped",
+		"mob_id":    mob.State.ID,
+		"killer_id": killer.State.ID,
+		"gold":      gold,
+	}
+	if len(items) > 0 {
+		payload["loot"] = z.spawnLootLocked(mob.State.X, mob.State.Y, killer.State.ID, items).State
+	}
+	return zoneEvent{Global: true, Payload: payload}
+}
+
+// spawnLootLocked leaves a pile on the ground. Only owner may pick it up;
+// uuid.Nil makes it free for all.
+func (z *zone) spawnLootLocked(x, y float64, owner uuid.UUID, items []domainworld.LootItem) *groundLoot {
+	z.lootSeq++
+	loot := &groundLoot{
+		State: domainworld.GroundLoot{
+			ID:      fmt.Sprintf("loot-%d", z.lootSeq),
+			X:       x,
+			Y:       y,
+			OwnerID: owner,
+			Items:   items,
+			ZoneID:  z.id,
+		},
+		ExpiresTick: z.tick + groundLootTicks,
+	}
+	z.loot[loot.State.ID] = loot
+	return loot
+}
+
+func (z *zone) expireLootLocked() []zoneEvent {
+	events := make([]zoneEvent, 0)
+	for id, loot := range z.loot {
+		if z.tick < loot.ExpiresTick {
+			continue
+		}
+		delete(z.loot, id)
+		events = append(events, zoneEvent{Global: true, Payload: map[string]any{"type": "loot_expired", "loot_id": id}})
+	}
+	return events
+}
+
+func (z *zone) groundLootLocked() []domainworld.GroundLoot {
+	loot := make([]domainworld.GroundLoot, 0, len(z.loot))
+	for _, l := range z.loot {
+		loot = append(loot, l.State)
+	}
+	return loot
+}
@@ -0,0 +1,330 @@
+package world
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	domainworld "mmorp-server/internal/domain/world"
+)
+
+type MapJSON struct {
+	ZoneID      string                  `json:"zone_id"`
+	Width       int                     `json:"width"`
+	Height      int                     `json:"height"`
+	Spawn       domainworld.SpawnPoint  `json:"spawn"`
+	Rows        []string                `json:"rows"`
+	NPCs        []NPCJSON               `json:"npcs"`
+	SpawnGroups []SpawnGroupJSON        `json:"spawn_groups"`
+	Portals     []PortalJSON            `json:"portals"`
+	Graveyards  []domainworld.Graveyard `json:"graveyards"`
+}
+
+type PortalJSON struct {
+	ID          string                 `json:"id"`
+	X           float64                `json:"x"`
+	Y           float64                `json:"y"`
+	Width       float64                `json:"width"`
+	Height      float64                `json:"height"`
+	TargetZone  string                 `json:"target_zone"`
+	TargetSpawn domainworld.SpawnPoint `json:"target_spawn"`
+}
+
+type NPCJSON struct {
+	ID           string      `json:"id"`
+	Name         string      `json:"name"`
+	Role         string      `json:"role"`
+	Interactions []string    `json:"interactions"`
+	Dialogue     string      `json:"dialogue"`
+	DialogueID   string      `json:"dialogue_id"`
+	Stock        []StockJSON `json:"stock"`
+	GoldPrice    int         `json:"gold_price"`
+	X            float64     `json:"x"`
+	Y            float64     `json:"y"`
+}
+
+// StockJSON is one item a merchant sells. Price defaults to the item's value.
+type StockJSON struct {
+	ItemID string `json:"item_id"`
+	Price  int    `json:"price"`
+}
+
+// SpawnGroupJSON places Count mobs of one template evenly around (X, Y).
+// Mob IDs are the group ID suffixed with -1, -2, ...
+type SpawnGroupJSON struct {
+	ID           string  `json:"id"`
+	Template     string  `json:"template"`
+	X            float64 `json:"x"`
+	Y            float64 `json:"y"`
+	Count        int     `json:"count"`
+	Radius       float64 `json:"radius"`
+	PatrolRadius float64 `json:"patrol_radius"`
+	LeashRadius  float64 `json:"leash_radius"`
+}
+
+type mobSpawn struct {
+	State    domainworld.MobState
+	Template domainworld.MobTemplate
+}
+
+type zoneData struct {
+	ID   string
+	Map  domainworld.TileMap
+	NPCs []domainworld.NPC
+	Mobs []mobSpawn
+}
+
+func loadZoneDir(dir string, cat catalog) ([]zoneData, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("read map dir: %w", err)
+	}
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		names = append(names, e.Name())
+	}
+	sort.Strings(names)
+
+	zones := make([]zoneData, 0, len(names))
+	seen := make(map[string]string, len(names))
+	for _, name := range names {
+		zd, err := loadWorldMap(filepath.Join(dir, name), cat)
+		if err != nil {
+			return nil, fmt.Errorf("load map %s: %w", name, err)
+		}
+		if prev, ok := seen[zd.ID]; ok {
+			return nil, fmt.Errorf("zone %q defined by both %s and %s", zd.ID, prev, name)
+		}
+		seen[zd.ID] = name
+		zones = append(zones, zd)
+	}
+	return zones, nil
+}
+
+func loadWorldMap(path string, cat catalog) (zoneData, error) {
+	if path == "" {
+		return zoneData{}, fmt.Errorf("empty world map path")
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return zoneData{}, fmt.Errorf("read world map: %w", err)
+	}
+	var data MapJSON
+	if err := json.Unmarshal(b, &data); err != nil {
+		return zoneData{}, fmt.Errorf("parse world map json: %w", err)
+	}
+	zoneID := data.ZoneID
+	if zoneID == "" {
+		zoneID = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	if data.Width <= 0 || data.Height <= 0 {
+		return zoneData{}, fmt.Errorf("invalid map dimensions")
+	}
+	if len(data.Rows) != data.Height {
+		return zoneData{}, fmt.Errorf("rows count must equal height")
+	}
+	tiles := make([][]domainworld.TileType, data.Height)
+	for y := 0; y < data.Height; y++ {
+		if len(data.Rows[y]) != data.Width {
+			return zoneData{}, fmt.Errorf("row %d width mismatch", y)
+		}
+		row := make([]domainworld.TileType, data.Width)
+		for x, r := range data.Rows[y] {
+			switch r {
+			case '.':
+				row[x] = domainworld.TileGrass
+			case '~':
+				row[x] = domainworld.TileWater
+			case '#':
+				row[x] = domainworld.TileWall
+			case '^':
+				row[x] = domainworld.TileForest
+			default:
+				return zoneData{}, fmt.Errorf("unknown tile rune %q", string(r))
+			}
+		}
+		tiles[y] = row
+	}
+
+	npcs := make([]domainworld.NPC, 0, len(data.NPCs))
+	for _, npc := range data.NPCs {
+		if npc.ID == "" {
+			continue
+		}
+		stock := make([]domainworld.StockItem, 0, len(npc.Stock))
+		for _, st := range npc.Stock {
+			item, ok := cat.items[st.ItemID]
+			if !ok {
+				return zoneData{}, fmt.Errorf("npc %q stocks unknown item %q", npc.ID, st.ItemID)
+			}
+			price := st.Price
+			if price <= 0 {
+				price = item.Value
+			}
+			if price < sellPrice(item) {
+				return zoneData{}, fmt.Errorf("npc %q sells %q for %d, below its sell price %d", npc.ID, st.ItemID, price, sellPrice(item))
+			}
+			stock = append(stock, domainworld.StockItem{ItemID: item.ID, Name: item.Name, Price: price})
+		}
+		if _, ok := cat.dialogues[npc.DialogueID]; npc.DialogueID != "" && !ok {
+			return zoneData{}, fmt.Errorf("npc %q references unknown dialogue %q", npc.ID, npc.DialogueID)
+		}
+		interactions := make([]string, 0, len(npc.Interactions))
+		for _, interaction := range npc.Interactions {
+			interactions = append(interactions, strings.ToLower(interaction))
+		}
+		npcs = append(npcs, domainworld.NPC{
+			ID:           npc.ID,
+			Name:         npc.Name,
+			Role:         npc.Role,
+			Interactions: interactions,
+			Dialogue:     npc.Dialogue,
+			DialogueID:   npc.DialogueID,
+			Stock:        stock,
+			GoldPrice:    npc.GoldPrice,
+			X:            npc.X,
+			Y:            npc.Y,
+			ZoneID:       zoneID,
+		})
+	}
+
+	mobs := make([]mobSpawn, 0, len(data.SpawnGroups))
+	seenMobs := make(map[string]struct{})
+	for _, g := range data.SpawnGroups {
+		if g.ID == "" {
+			return zoneData{}, fmt.Errorf("spawn group without id")
+		}
+		tmpl, ok := cat.mobs[g.Template]
+		if !ok {
+			return zoneData{}, fmt.Errorf("spawn group %q references unknown mob template %q", g.ID, g.Template)
+		}
+		count := g.Count
+		if count <= 0 {
+			count = 1
+		}
+		patrol := g.PatrolRadius
+		if patrol <= 0 {
+			patrol = mobDefaultPatrolRadius
+		}
+		leash := g.LeashRadius
+		if leash < patrol {
+			leash = patrol + mobDefaultLeashSlack
+		}
+		for i := 0; i < count; i++ {
+			id := fmt.Sprintf("%s-%d", g.ID, i+1)
+			if _, dup := seenMobs[id]; dup {
+				return zoneData{}, fmt.Errorf("duplicate mob id %q", id)
+			}
+			seenMobs[id] = struct{}{}
+			x, y := g.X, g.Y
+			if count > 1 && g.Radius > 0 {
+				ang := 2 * math.Pi * float64(i) / float64(count)
+				nx, ny := x+math.Cos(ang)*g.Radius, y+math.Sin(ang)*g.Radius
+				if walkableTile(tiles, nx, ny) {
+					x, y = nx, ny
+				}
+			}
+			mobs = append(mobs, mobSpawn{
+				Template: tmpl,
+				State:    newMobState(id, zoneID, tmpl, x, y, patrol, leash),
+			})
+		}
+	}
+
+	portals := make([]domainworld.Portal, 0, len(data.Portals))
+	for _, p := range data.Portals {
+		if p.TargetZone == "" {
+			return zoneData{}, fmt.Errorf("portal %q has no target zone", p.ID)
+		}
+		width, height := p.Width, p.Height
+		if width <= 0 {
+			width = 1
+		}
+		if height <= 0 {
+			height = 1
+		}
+		portals = append(portals, domainworld.Portal{
+			ID:           p.ID,
+			X:            p.X,
+			Y:            p.Y,
+			Width:        width,
+			Height:       height,
+			TargetZoneID: p.TargetZone,
+			TargetSpawn:  p.TargetSpawn,
+		})
+	}
+
+	for _, g := range data.Graveyards {
+		if g.ID == "" {
+			return zoneData{}, fmt.Errorf("graveyard without id")
+		}
+		if !walkableTile(tiles, g.X, g.Y) {
+			return zoneData{}, fmt.Errorf("graveyard %q is not on a walkable tile", g.ID)
+		}
+	}
+
+	return zoneData{
+		ID:   zoneID,
+		Map:  domainworld.TileMap{Width: data.Width, Height: data.Height, Spawn: data.Spawn, Tiles: tiles, Portals: portals, Graveyards: data.Graveyards},
+		NPCs: npcs,
+		Mobs: mobs,
+	}, nil
+}
+
+func newMobState(id, zoneID string, tmpl domainworld.MobTemplate, x, y, patrol, leash float64) domainworld.MobState {
+	return domainworld.MobState{
+		ID:           id,
+		TemplateID:   tmpl.ID,
+		Name:         tmpl.Name,
+		Level:        tmpl.Level,
+		X:            x,
+		Y:            y,
+		HP:           tmpl.HP,
+		MaxHP:        tmpl.HP,
+		Damage:       tmpl.Damage,
+		PatrolRadius: patrol,
+		LeashRadius:  leash,
+		ZoneID:       zoneID,
+		Alive:        true,
+		AIState:      domainworld.MobAIStateIdle,
+	}
+}
+
+func walkableTile(tiles [][]domainworld.TileType, x, y float64) bool {
+	tx, ty := int(math.Floor(x)), int(math.Floor(y))
+	if ty < 0 || ty >= len(tiles) || tx < 0 || tx >= len(tiles[ty]) {
+		return false
+	}
+	t := tiles[ty][tx]
+	return t != domainworld.TileWall && t != domainworld.TileWater
+}
+
+func fallbackWorld(zoneID string) zoneData {
+	width, height := 50, 50
+	tiles := make([][]domainworld.TileType, height)
+	for y := 0; y < height; y++ {
+		row := make([]domainworld.TileType, width)
+		for x := 0; x < width; x++ {
+			if x == 0 || y == 0 || x == width-1 || y == height-1 {
+				row[x] = domainworld.TileWall
+				continue
+			}
+			row[x] = domainworld.TileGrass
+		}
+		tiles[y] = row
+	}
+	slime := withMobDefaults(domainworld.MobTemplate{ID: "green-slime", Name: "Green Slime", HP: 60, Damage: 8, XPReward: 25})
+	return zoneData{
+		ID:   zoneID,
+		Map:  domainworld.TileMap{Width: width, Height: height, Spawn: domainworld.SpawnPoint{X: 2.5, Y: 2.5}, Tiles: tiles},
+		NPCs: []domainworld.NPC{{ID: "npc-merchant-1", Name: "Rurik", Role: "merchant", Interactions: []string{"talk", "trade", "heal"}, Dialogue: "Welcome, traveler! What can I offer you today?", GoldPrice: 50, X: 5, Y: 5, ZoneID: zoneID}},
+		Mobs: []mobSpawn{{Template: slime, State: newMobState("mob-slime-1", zoneID, slime, 14, 12, 6, 6+mobDefaultLeashSlack)}},
+	}
+}
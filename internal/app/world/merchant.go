@@ -0,0 +1,91 @@
+package world
+
+import (
+	"fmt"
+
+	domainworld "mmorp-server/internal/domain/world"
+)
+
+const (
+	npcActionBuy        = "buy"
+	npcActionSell       = "sell"
+	npcInteractRange    = 3.0
+	merchantSellDivisor = 2
+	merchantMaxQuantity = 100
+)
+
+func sellPrice(item domainworld.Item) int {
+	return item.Value / merchantSellDivisor
+}
+
+func (s *Service) sellPrices(inv *inventory) map[string]int {
+	prices := make(map[string]int)
+	for _, slot := range inv.slots {
+		if slot.ItemID == "" {
+			continue
+		}
+		if p := sellPrice(s.items[slot.ItemID]); p > 0 {
+			prices[slot.ItemID] = p
+		}
+	}
+	return prices
+}
+
+func inNPCRange(pr *playerRuntime, npc *domainworld.NPC) bool {
+	return distance(pr.State.X, pr.State.Y, npc.X, npc.Y) <= npcInteractRange
+}
+
+// buyLocked checks gold, stock and bag space before changing anything, so a
+// purchase either fully happens or leaves the player untouched.
+func (s *Service) buyLocked(pr *playerRuntime, npc *domainworld.NPC, itemID string, qty int) (map[string]any, error) {
+	if !inNPCRange(pr, npc) {
+		return nil, fmt.Errorf("merchant out of range")
+	}
+	if qty <= 0 {
+		qty = 1
+	}
+	if qty > merchantMaxQuantity {
+		return nil, fmt.Errorf("cannot buy more than %d at once", merchantMaxQuantity)
+	}
+	var stock *domainworld.StockItem
+	for i := range npc.Stock {
+		if npc.Stock[i].ItemID == itemID {
+			stock = &npc.Stock[i]
+			break
+		}
+	}
+	if stock == nil {
+		return nil, fmt.Errorf("%s does not sell that", npc.Name)
+	}
+	cost := stock.Price * qty
+	if pr.State.Gold < cost {
+		return nil, fmt.Errorf("not enough gold (need %d)", cost)
+	}
+	if pr.Inventory.Room(itemID) < qty {
+		return nil, fmt.Errorf("inventory full")
+	}
+	pr.Inventory.Add(itemID, qty)
+	pr.State.Gold -= cost
+	return map[string]any{"success": true, "item_id": itemID, "quantity": qty, "gold_spent": cost}, nil
+}
+
+func (s *Service) sellLocked(pr *playerRuntime, npc *domainworld.NPC, slot, qty int) (map[string]any, error) {
+	if !inNPCRange(pr, npc) {
+		return nil, fmt.Errorf("merchant out of range")
+	}
+	if slot < 0 || slot >= len(pr.Inventory.slots) || pr.Inventory.slots[slot].ItemID == "" {
+		return nil, fmt.Errorf("inventory slot is empty")
+	}
+	item := s.items[pr.Inventory.slots[slot].ItemID]
+	price := sellPrice(item)
+	if price <= 0 {
+		return nil, fmt.Errorf("%s cannot be sold", item.Name)
+	}
+	taken, err := pr.Inventory.Take(slot, qty)
+	if err != nil {
+		return nil, err
+	}
+	earned := price * taken.Quantity
+	pr.State.Gold += earned
+	return map[string]any{"success": true, "item_id": item.ID, "quantity": taken.Quantity, "gold_earned": earned}, nil
+}
@@ -0,0 +1,321 @@
+package world
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/google/uuid"
+
+	domainworld "mmorp-server/internal/domain/world"
+)
+
+const (
+	mobIdleMinTicks        = 10
+	mobIdleMaxTicks        = 30
+	mobEvadeMaxTicks       = 150
+	mobDefaultLeashSlack   = 6.0
+	mobDefaultPatrolRadius = 5.0
+)
+
+func (mob *mobRuntime) setAIState(state domainworld.MobAIState) {
+	if mob.State.AIState == state {
+		return
+	}
+	mob.State.AIState = state
+	mob.clearPath()
+	switch state {
+	case domainworld.MobAIStateIdle, domainworld.MobAIStateWander:
+		mob.TargetID = uuid.Nil
+		mob.wipeThreat()
+		mob.WanderTicksRemain = 0
+		mob.IdleTicksRemain = 0
+	case domainworld.MobAIStateEvade:
+		mob.TargetID = uuid.Nil
+		mob.wipeThreat()
+		mob.EvadeTicks = 0
+		mob.State.HP = mob.State.MaxHP
+		mob.clearEffects()
+		mob.resetAttacks()
+	case domainworld.MobAIStateDead:
+		mob.TargetID = uuid.Nil
+		mob.wipeThreat()
+		mob.clearEffects()
+		mob.resetAttacks()
+	}
+}
+
+func (mob *mobRuntime) engage(playerID uuid.UUID, threat float64) {
+	if !mob.State.Alive || mob.State.AIState == domainworld.MobAIStateEvade {
+		return
+	}
+	if mob.State.AIState != domainworld.MobAIStateAttack {
+		mob.setAIState(domainworld.MobAIStateChase)
+	}
+	mob.addThreat(playerID, threat)
+	if mob.TargetID == uuid.Nil {
+		mob.TargetID = playerID
+	}
+}
+
+func (mob *mobRuntime) die() {
+	mob.State.Alive = false
+	mob.State.HP = 0
+	mob.RespawnCounter = mob.Template.RespawnTicks
+	mob.setAIState(domainworld.MobAIStateDead)
+}
+
+func (mob *mobRuntime) inPatrol(x, y float64) bool {
+	return distance(mob.SpawnX, mob.SpawnY, x, y) <= mob.State.PatrolRadius
+}
+
+func (mob *mobRuntime) inLeash(x, y float64) bool {
+	return distance(mob.SpawnX, mob.SpawnY, x, y) <= mob.State.LeashRadius
+}
+
+func (z *zone) stepMobsLocked() []zoneEvent {
+	events := make([]zoneEvent, 0)
+	for _, mob := range z.mobs {
+		if mob.Mods.Stunned && mob.State.Alive {
+			continue
+		}
+		switch mob.State.AIState {
+		case domainworld.MobAIStateDead:
+			events = append(events, z.stepDeadMobLocked(mob)...)
+		case domainworld.MobAIStateEvade:
+			z.stepEvadingMobLocked(mob)
+		case domainworld.MobAIStateChase, domainworld.MobAIStateAttack:
+			events = append(events, z.stepEngagedMobLocked(mob)...)
+		default:
+			z.stepIdleMobLocked(mob)
+		}
+	}
+	return events
+}
+
+func (z *zone) stepDeadMobLocked(mob *mobRuntime) []zoneEvent {
+	if mob.RespawnCounter > 0 {
+		mob.RespawnCounter--
+	}
+	if mob.RespawnCounter > 0 {
+		return nil
+	}
+	mob.State.Alive = true
+	mob.State.HP = mob.State.MaxHP
+	mob.State.X = mob.SpawnX
+	mob.State.Y = mob.SpawnY
+	mob.setAIState(domainworld.MobAIStateIdle)
+	return []zoneEvent{{
+		Global: true,
+		Payload: map[string]any{
+			"type":    "broadcast",
+			"message": fmt.Sprintf("%s has respawned", mob.State.Name),
+		},
+	}}
+}
+
+func (z *zone) stepIdleMobLocked(mob *mobRuntime) {
+	if target := z.closestPlayerInRangeLocked(mob.State.X, mob.State.Y, mob.Template.AggroRange); target != nil {
+		mob.engage(target.State.ID, mobProximityThreat)
+		return
+	}
+
+	if mob.State.AIState == domainworld.MobAIStateIdle {
+		if mob.IdleTicksRemain <= 0 {
+			mob.IdleTicksRemain = mobIdleMinTicks + z.rand.Intn(mobIdleMaxTicks-mobIdleMinTicks+1)
+		}
+		mob.IdleTicksRemain--
+		if mob.IdleTicksRemain == 0 {
+			mob.setAIState(domainworld.MobAIStateWander)
+		}
+		return
+	}
+
+	if mob.WanderTicksRemain <= 0 {
+		ang := z.rand.Float64() * 2 * math.Pi
+		mob.WanderDX = math.Cos(ang) * mob.moveSpeed() * 0.7
+		mob.WanderDY = math.Sin(ang) * mob.moveSpeed() * 0.7
+		mob.WanderTicksRemain = 5 + z.rand.Intn(mobWanderMaxTicks)
+	}
+	mob.WanderTicksRemain--
+	nx := mob.State.X + mob.WanderDX
+	ny := mob.State.Y + mob.WanderDY
+	if mob.inPatrol(nx, ny) && z.isWalkableWithRadius(nx, ny, mobCollisionRadius) {
+		mob.State.X = nx
+		mob.State.Y = ny
+	} else {
+		mob.WanderTicksRemain = 0
+	}
+	if mob.WanderTicksRemain <= 0 {
+		mob.setAIState(domainworld.MobAIStateIdle)
+	}
+}
+
+func (z *zone) stepEngagedMobLocked(mob *mobRuntime) []zoneEvent {
+	z.updateThreatLocked(mob)
+	target := z.selectTargetLocked(mob)
+	if target == nil || !mob.inLeash(mob.State.X, mob.State.Y) {
+		mob.setAIState(domainworld.MobAIStateEvade)
+		return nil
+	}
+
+	d := distance(target.State.X, target.State.Y, mob.State.X, mob.State.Y)
+	attack, ok := z.chooseMobAttackLocked(mob, d)
+	if d > z.mobReachLocked(mob) {
+		mob.setAIState(domainworld.MobAIStateChase)
+		z.moveMobAlongPathLocked(mob, target.State.X, target.State.Y, mob.inLeash)
+	} else {
+		mob.setAIState(domainworld.MobAIStateAttack)
+	}
+	if !ok {
+		return nil
+	}
+	return z.applyMobAttackLocked(mob, attack, target)
+}
+
+func (z *zone) stepEvadingMobLocked(mob *mobRuntime) {
+	mob.EvadeTicks++
+	arrived := distance(mob.State.X, mob.State.Y, mob.SpawnX, mob.SpawnY) <= mob.Template.MoveSpeed
+	if arrived || mob.EvadeTicks >= mobEvadeMaxTicks {
+		mob.State.X, mob.State.Y = mob.SpawnX, mob.SpawnY
+		mob.setAIState(domainworld.MobAIStateIdle)
+		return
+	}
+	z.moveMobAlongPathLocked(mob, mob.SpawnX, mob.SpawnY, mob.inLeash)
+}
+
+func (mob *mobRuntime) resetAttacks() {
+	mob.Cooldowns = nil
+	mob.Enraged = false
+}
+
+func (mob *mobRuntime) hurtEnoughFor(a domainworld.MobAttack) bool {
+	return a.HPBelow <= 0 || float64(mob.State.HP) < a.HPBelow*float64(mob.State.MaxHP)
+}
+
+// canUseAttack reports whether an attack is off cooldown and the mob is
+// hurt enough for it. Enrage is used once per fight.
+func (z *zone) canUseAttack(mob *mobRuntime, a domainworld.MobAttack) bool {
+	if !mob.hurtEnoughFor(a) {
+		return false
+	}
+	if a.Kind == domainworld.MobAttackEnrage && mob.Enraged {
+		return false
+	}
+	return z.tick >= mob.Cooldowns[a.ID]
+}
+
+// chooseMobAttackLocked picks the first attack in the template's list that
+// the mob can use on a target d tiles away. Designers order attacks by
+// priority.
+func (z *zone) chooseMobAttackLocked(mob *mobRuntime, d float64) (domainworld.MobAttack, bool) {
+	for _, a := range mob.Template.Attacks {
+		if !z.canUseAttack(mob, a) {
+			continue
+		}
+		if a.Kind == domainworld.MobAttackEnrage || d <= a.Range {
+			return a, true
+		}
+	}
+	return domainworld.MobAttack{}, false
+}
+
+// mobReachLocked is how close a mob closes in on its target: the longest
+// range among the attacks it can use now or, while they all cool down,
+// among every attack its HP allows.
+func (z *zone) mobReachLocked(mob *mobRuntime) float64 {
+	ready, allowed := 0.0, 0.0
+	for _, a := range mob.Template.Attacks {
+		if a.Kind == domainworld.MobAttackEnrage || !mob.hurtEnoughFor(a) {
+			continue
+		}
+		allowed = max(allowed, a.Range)
+		if z.tick >= mob.Cooldowns[a.ID] {
+			ready = max(ready, a.Range)
+		}
+	}
+	if ready > 0 {
+		return ready
+	}
+	return allowed
+}
+
+// applyMobAttackLocked uses one of a mob's attacks: on its target, on every
+// player within a slam's radius, or on the mob itself for an enrage.
+func (z *zone) applyMobAttackLocked(mob *mobRuntime, a domainworld.MobAttack, target *playerRuntime) []zoneEvent {
+	if a.CooldownTicks > 0 {
+		if mob.Cooldowns == nil {
+			mob.Cooldowns = make(map[string]uint64)
+		}
+		mob.Cooldowns[a.ID] = z.tick + uint64(a.CooldownTicks)
+	}
+	if a.Kind == domainworld.MobAttackEnrage {
+		mob.Enraged = true
+		events := []zoneEvent{{X: mob.State.X, Y: mob.State.Y, Payload: map[string]any{"type": "mob_enraged", "mob_id": mob.State.ID, "attack_id": a.ID}}}
+		for _, id := range a.Effects {
+			events = append(events, z.applyMobEffectLocked(mob, id, mob.State.ID)...)
+		}
+		return events
+	}
+	targets := []*playerRuntime{target}
+	if a.Kind == domainworld.MobAttackSlam {
+		targets = targets[:0]
+		for _, pr := range z.playersNearLocked(mob.State.X, mob.State.Y, a.Radius) {
+			if pr.State.HP > 0 && distance(mob.State.X, mob.State.Y, pr.State.X, pr.State.Y) <= a.Radius {
+				targets = append(targets, pr)
+			}
+		}
+	}
+	events := make([]zoneEvent, 0, len(targets))
+	for _, pr := range targets {
+		events = append(events, z.hitPlayerLocked(mob, a, pr)...)
+	}
+	return events
+}
+
+func (z *zone) hitPlayerLocked(mob *mobRuntime, a domainworld.MobAttack, pr *playerRuntime) []zoneEvent {
+	base := a.Damage
+	if base == 0 {
+		base = mob.State.Damage
+	}
+	dmg := mitigateDamage(max(0, base+mob.Mods.Stats.AttackPower), pr.State.Stats.Armor)
+	events := []zoneEvent{{
+		X: mob.State.X,
+		Y: mob.State.Y,
+		Payload: map[string]any{
+			"type":      "combat",
+			"attacker":  mob.State.ID,
+			"target":    pr.State.ID.String(),
+			"damage":    dmg,
+			"attack_id": a.ID,
+			"kind":      a.Kind,
+		},
+	}}
+	pr.State.HP -= dmg
+	events = append(events, z.enterCombatLocked(pr)...)
+	if pr.State.HP <= 0 {
+		return append(events, z.killPlayerLocked(pr)...)
+	}
+	for _, id := range mob.Template.Effects {
+		events = append(events, z.applyPlayerEffectLocked(pr, id, mob.State.ID)...)
+	}
+	for _, id := range a.Effects {
+		events = append(events, z.applyPlayerEffectLocked(pr, id, mob.State.ID)...)
+	}
+	return events
+}
+
+func (z *zone) closestPlayerInRangeLocked(x, y, rng float64) *playerRuntime {
+	var best *playerRuntime
+	bestDist := math.MaxFloat64
+	for _, p := range z.playersNearLocked(x, y, rng) {
+		if p.State.HP <= 0 {
+			continue
+		}
+		d := distance(x, y, p.State.X, p.State.Y)
+		if d <= rng && d < bestDist {
+			best = p
+			bestDist = d
+		}
+	}
+	return best
+}
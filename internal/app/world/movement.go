@@ -0,0 +1,103 @@
+package world
+
+import (
+	"math"
+
+	domainworld "mmorp-server/internal/domain/world"
+)
+
+type moveInput struct {
+	DX  float64
+	DY  float64
+	Seq uint64
+}
+
+type movedPlayer struct {
+	Client  *Client
+	State   domainworld.PlayerState
+	Portal  *domainworld.Portal
+	Changed bool
+	// Persist is set at most once per positionSaveTicks while the player
+	// keeps moving.
+	Persist bool
+	Seq     uint64
+	Tick    uint64
+}
+
+func normalizeMove(dx, dy float64) (float64, float64, bool) {
+	if math.Abs(dx) < 1e-6 && math.Abs(dy) < 1e-6 {
+		return 0, 0, false
+	}
+	norm := math.Hypot(dx, dy)
+	if norm > 1 {
+		dx /= norm
+		dy /= norm
+	}
+	return dx, dy, true
+}
+
+// queueMove buffers a move intent. Clients that predict locally number their
+// inputs; anything older than the newest input already seen is dropped.
+// Inputs without a sequence number are numbered on arrival.
+func (pr *playerRuntime) queueMove(dx, dy float64, seq uint64) {
+	if seq == 0 {
+		seq = pr.InputSeq + 1
+	}
+	if seq <= pr.InputSeq {
+		return
+	}
+	pr.InputSeq = seq
+	pr.PendingMove = &moveInput{DX: dx, DY: dy, Seq: seq}
+}
+
+// applyMovementLocked consumes at most one buffered move per player, so a
+// player covers at most playerMoveSpeed per tick however often they send.
+// Slows scale the distance; stunned or rooted players stay put but still
+// have their input acknowledged.
+func (z *zone) applyMovementLocked() []movedPlayer {
+	moved := make([]movedPlayer, 0)
+	for _, pr := range z.players {
+		in := pr.PendingMove
+		if in == nil {
+			continue
+		}
+		pr.PendingMove = nil
+		if in.Seq <= pr.LastMoveSeq {
+			continue
+		}
+		pr.LastMoveSeq = in.Seq
+		pr.Client.lastInputSeq.Store(in.Seq)
+		prevX, prevY := pr.State.X, pr.State.Y
+		speed := pr.Mods.speed(playerMoveSpeed)
+
+		nextX := pr.State.X + in.DX*speed
+		nextY := pr.State.Y
+		if z.isWalkableWithRadius(nextX, nextY, playerCollisionRadius) {
+			pr.State.X = nextX
+		}
+		nextX = pr.State.X
+		nextY = pr.State.Y + in.DY*speed
+		if z.isWalkableWithRadius(nextX, nextY, playerCollisionRadius) {
+			pr.State.Y = nextY
+		}
+		z.playerGrid.Upsert(pr.State.ID, pr.State.X, pr.State.Y)
+
+		m := movedPlayer{
+			Client:  pr.Client,
+			State:   pr.State,
+			Changed: pr.State.X != prevX || pr.State.Y != prevY,
+			Seq:     in.Seq,
+			Tick:    z.tick,
+		}
+		if m.Changed && z.tick >= pr.NextPositionSave {
+			m.Persist = true
+			pr.NextPositionSave = z.tick + positionSaveTicks
+		}
+		// Ghosts stay in the zone of their corpse.
+		if portal, ok := z.portalAt(pr.State.X, pr.State.Y); ok && !pr.State.Dead {
+			m.Portal = &portal
+		}
+		moved = append(moved, m)
+	}
+	return moved
+}
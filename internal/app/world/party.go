@@ -0,0 +1,345 @@
+package world
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	domainworld "mmorp-server/internal/domain/world"
+)
+
+const (
+	maxPartySize   = 5
+	partyInviteTTL = 60 * time.Second
+	// partyXPRange is how close to a kill a party member must be to share
+	// its XP and quest credit.
+	partyXPRange    = 30.0
+	partyFrameTicks = 5
+)
+
+type party struct {
+	ID       string
+	LeaderID uuid.UUID
+	Members  []partyMember
+}
+
+type partyMember struct {
+	ID     uuid.UUID
+	Name   string
+	Client *Client
+}
+
+type partyInvite struct {
+	From    partyMember
+	Expires time.Time
+}
+
+func (p *party) index(id uuid.UUID) int {
+	for i, m := range p.Members {
+		if m.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (p *party) memberIDs() []uuid.UUID {
+	ids := make([]uuid.UUID, 0, len(p.Members))
+	for _, m := range p.Members {
+		ids = append(ids, m.ID)
+	}
+	return ids
+}
+
+// sendPartyLocked sends to every member. s.socialMu must be held; members
+// are online for as long as they are in a party, so their channels are open.
+func (s *Service) sendPartyLocked(p *party, payload any) {
+	for _, m := range p.Members {
+		nonBlockingSendJSON(m.Client.Send, payload)
+	}
+}
+
+func (s *Service) partyRosterLocked(p *party) map[string]any {
+	members := make([]map[string]any, 0, len(p.Members))
+	for _, m := range p.Members {
+		members = append(members, map[string]any{"id": m.ID, "name": m.Name})
+	}
+	return map[string]any{"type": "party_update", "party": map[string]any{"id": p.ID, "leader_id": p.LeaderID, "members": members}}
+}
+
+// partyMemberIDs returns the IDs of everyone in the character's party,
+// including the character, or nil outside a party.
+func (s *Service) partyMemberIDs(characterID uuid.UUID) []uuid.UUID {
+	s.socialMu.Lock()
+	defer s.socialMu.Unlock()
+	if p, ok := s.playerParties[characterID]; ok {
+		return p.memberIDs()
+	}
+	return nil
+}
+
+// sendToParty delivers payload to the character's party and reports the
+// party ID, or false outside a party.
+func (s *Service) sendToParty(characterID uuid.UUID, payload any) (string, bool) {
+	s.socialMu.Lock()
+	defer s.socialMu.Unlock()
+	p, ok := s.playerParties[characterID]
+	if !ok {
+		return "", false
+	}
+	s.sendPartyLocked(p, payload)
+	return p.ID, true
+}
+
+func (s *Service) PartyInvite(c *Client, name string) {
+	s.socialMu.Lock()
+	defer s.socialMu.Unlock()
+	if err := s.partyInviteLocked(c.CharacterID, name); err != nil {
+		nonBlockingSendJSON(c.Send, map[string]any{"type": "error", "message": err.Error()})
+	}
+}
+
+func (s *Service) partyInviteLocked(fromID uuid.UUID, name string) error {
+	from, ok := s.online[fromID]
+	if !ok {
+		return fmt.Errorf("you are not in the world")
+	}
+	target, err := s.onlineByNameLocked(name)
+	if err != nil {
+		return err
+	}
+	if target.ID == from.ID {
+		return fmt.Errorf("you cannot invite yourself")
+	}
+	if p, ok := s.playerParties[from.ID]; ok {
+		if p.LeaderID != from.ID {
+			return fmt.Errorf("only the party leader can invite")
+		}
+		if len(p.Members) >= maxPartySize {
+			return fmt.Errorf("party is full")
+		}
+	}
+	if _, ok := s.playerParties[target.ID]; ok {
+		return fmt.Errorf("%s is already in a party", target.Name)
+	}
+	s.partyInvites[target.ID] = partyInvite{From: from, Expires: time.Now().Add(partyInviteTTL)}
+	nonBlockingSendJSON(target.Client.Send, map[string]any{"type": "party_invite", "from": from.Name, "from_id": from.ID})
+	return nil
+}
+
+func (s *Service) PartyAccept(c *Client) {
+	s.socialMu.Lock()
+	defer s.socialMu.Unlock()
+	if err := s.partyAcceptLocked(c.CharacterID); err != nil {
+		nonBlockingSendJSON(c.Send, map[string]any{"type": "error", "message": err.Error()})
+	}
+}
+
+// partyAcceptLocked joins the inviter's party, forming one with the inviter
+// as leader if needed. Invites are dropped when either side disconnects.
+func (s *Service) partyAcceptLocked(id uuid.UUID) error {
+	member, ok := s.online[id]
+	if !ok {
+		return fmt.Errorf("you are not in the world")
+	}
+	inv, ok := s.partyInvites[id]
+	delete(s.partyInvites, id)
+	if !ok || time.Now().After(inv.Expires) {
+		return fmt.Errorf("no pending party invite")
+	}
+	if _, ok := s.playerParties[member.ID]; ok {
+		return fmt.Errorf("you are already in a party")
+	}
+	p, ok := s.playerParties[inv.From.ID]
+	if !ok {
+		s.partySeq++
+		p = &party{ID: fmt.Sprintf("party-%d", s.partySeq), LeaderID: inv.From.ID, Members: []partyMember{inv.From}}
+		s.playerParties[inv.From.ID] = p
+	}
+	if p.LeaderID != inv.From.ID {
+		return fmt.Errorf("%s is no longer leading a party", inv.From.Name)
+	}
+	if len(p.Members) >= maxPartySize {
+		return fmt.Errorf("party is full")
+	}
+	p.Members = append(p.Members, member)
+	s.playerParties[member.ID] = p
+	s.sendPartyLocked(p, s.partyRosterLocked(p))
+	return nil
+}
+
+func (s *Service) PartyDecline(c *Client) {
+	s.socialMu.Lock()
+	defer s.socialMu.Unlock()
+	inv, ok := s.partyInvites[c.CharacterID]
+	if !ok {
+		return
+	}
+	delete(s.partyInvites, c.CharacterID)
+	nonBlockingSendJSON(inv.From.Client.Send, map[string]any{"type": "party_declined", "player_id": c.CharacterID})
+}
+
+func (s *Service) PartyLeave(c *Client) {
+	s.socialMu.Lock()
+	defer s.socialMu.Unlock()
+	if !s.removePartyMemberLocked(c.CharacterID, "left") {
+		nonBlockingSendJSON(c.Send, map[string]any{"type": "error", "message": "you are not in a party"})
+	}
+}
+
+func (s *Service) PartyKick(c *Client, name string) {
+	s.socialMu.Lock()
+	defer s.socialMu.Unlock()
+	p, ok := s.playerParties[c.CharacterID]
+	if !ok || p.LeaderID != c.CharacterID {
+		nonBlockingSendJSON(c.Send, map[string]any{"type": "error", "message": "only the party leader can kick"})
+		return
+	}
+	for _, m := range p.Members {
+		if m.ID != c.CharacterID && strings.EqualFold(m.Name, name) {
+			s.removePartyMemberLocked(m.ID, "kicked")
+			return
+		}
+	}
+	nonBlockingSendJSON(c.Send, map[string]any{"type": "error", "message": fmt.Sprintf("%s is not in your party", name)})
+}
+
+// onlineByNameLocked resolves a character name or ID to an online player.
+// Names are not unique, so a name shared by several online players is
+// rejected and the caller has to use the character ID instead.
+func (s *Service) onlineByNameLocked(name string) (partyMember, error) {
+	if id, err := uuid.Parse(name); err == nil {
+		if m, ok := s.online[id]; ok {
+			return m, nil
+		}
+	}
+	var found []partyMember
+	for _, m := range s.online {
+		if strings.EqualFold(m.Name, name) {
+			found = append(found, m)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return partyMember{}, fmt.Errorf("%s is not online", name)
+	case 1:
+		return found[0], nil
+	default:
+		return partyMember{}, fmt.Errorf("more than one player is named %s; use their character ID", name)
+	}
+}
+
+// addOnline records a player that joined the world so party and whisper
+// lookups can find them.
+func (s *Service) addOnline(m partyMember) {
+	s.socialMu.Lock()
+	s.online[m.ID] = m
+	s.socialMu.Unlock()
+}
+
+// removeOnline takes a disconnecting player out of their party and drops
+// invites to and from them. It must run before the client's channel closes.
+func (s *Service) removeOnline(id uuid.UUID) {
+	s.socialMu.Lock()
+	defer s.socialMu.Unlock()
+	s.removePartyMemberLocked(id, "disconnected")
+	for target, inv := range s.partyInvites {
+		if target == id || inv.From.ID == id {
+			delete(s.partyInvites, target)
+		}
+	}
+	delete(s.online, id)
+}
+
+// removePartyMemberLocked takes a player out of their party, handing
+// leadership to the next member and disbanding a party of one.
+func (s *Service) removePartyMemberLocked(id uuid.UUID, reason string) bool {
+	p, ok := s.playerParties[id]
+	if !ok {
+		return false
+	}
+	i := p.index(id)
+	removed := p.Members[i]
+	p.Members = append(p.Members[:i], p.Members[i+1:]...)
+	delete(s.playerParties, id)
+	nonBlockingSendJSON(removed.Client.Send, map[string]any{"type": "party_left", "party_id": p.ID, "reason": reason})
+
+	if len(p.Members) == 1 {
+		last := p.Members[0]
+		delete(s.playerParties, last.ID)
+		nonBlockingSendJSON(last.Client.Send, map[string]any{"type": "party_left", "party_id": p.ID, "reason": "disbanded"})
+		return true
+	}
+	if p.LeaderID == id {
+		p.LeaderID = p.Members[0].ID
+	}
+	s.sendPartyLocked(p, s.partyRosterLocked(p))
+	return true
+}
+
+// sendPartyFrames pushes HP and position of the zone's party members to
+// their whole party, wherever the other members are.
+func (s *Service) sendPartyFrames(z *zone) {
+	z.mu.RLock()
+	frames := make([]domainworld.PlayerState, 0, len(z.players))
+	for _, pr := range z.players {
+		frames = append(frames, pr.State)
+	}
+	z.mu.RUnlock()
+
+	s.socialMu.Lock()
+	defer s.socialMu.Unlock()
+	byParty := make(map[*party][]map[string]any)
+	for _, st := range frames {
+		p, ok := s.playerParties[st.ID]
+		if !ok {
+			continue
+		}
+		byParty[p] = append(byParty[p], map[string]any{
+			"id":      st.ID,
+			"hp":      st.HP,
+			"max_hp":  st.MaxHP,
+			"level":   st.Level,
+			"x":       st.X,
+			"y":       st.Y,
+			"zone_id": st.ZoneID,
+		})
+	}
+	for p, members := range byParty {
+		s.sendPartyLocked(p, map[string]any{"type": "party_frames", "party_id": p.ID, "members": members})
+	}
+}
+
+// killCreditLocked returns the players in z who share a kill: the killer's
+// party members in this zone within partyXPRange of the mob and alive, or
+// just the killer.
+func (s *Service) killCreditLocked(z *zone, killer *playerRuntime, mobX, mobY float64) []*playerRuntime {
+	ids := s.partyMemberIDs(killer.State.ID)
+	if ids == nil {
+		return []*playerRuntime{killer}
+	}
+	eligible := []*playerRuntime{killer}
+	for _, id := range ids {
+		pr, ok := z.players[id]
+		if !ok || pr == killer || pr.State.HP <= 0 {
+			continue
+		}
+		if distance(pr.State.X, pr.State.Y, mobX, mobY) <= partyXPRange {
+			eligible = append(eligible, pr)
+		}
+	}
+	return eligible
+}
+
+// splitXP divides xp evenly, giving the remainder to the first share (the
+// killer).
+func splitXP(xp, n int) []int {
+	shares := make([]int, n)
+	for i := range shares {
+		shares[i] = xp / n
+	}
+	shares[0] += xp % n
+	return shares
+}
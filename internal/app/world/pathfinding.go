@@ -0,0 +1,204 @@
+package world
+
+import (
+	"container/heap"
+	"math"
+)
+
+const (
+	mobCollisionRadius = 0.2
+	pathReplanTicks    = 10
+	pathGoalDrift      = 1.5
+	pathMaxExpanded    = 2048
+	lineOfSightStep    = 0.25
+)
+
+type pathPoint struct {
+	X float64
+	Y float64
+}
+
+type pathNode struct {
+	cell   gridCell
+	g      float64
+	f      float64
+	parent *pathNode
+	index  int
+}
+
+type pathQueue []*pathNode
+
+func (q pathQueue) Len() int           { return len(q) }
+func (q pathQueue) Less(i, j int) bool { return q[i].f < q[j].f }
+func (q pathQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+func (q *pathQueue) Push(x any) {
+	n := x.(*pathNode)
+	n.index = len(*q)
+	*q = append(*q, n)
+}
+func (q *pathQueue) Pop() any {
+	old := *q
+	n := old[len(old)-1]
+	old[len(old)-1] = nil
+	*q = old[:len(old)-1]
+	n.index = -1
+	return n
+}
+
+var pathNeighbours = [8][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}, {1, 1}, {1, -1}, {-1, 1}, {-1, -1}}
+
+func tileOf(x, y float64) gridCell {
+	return gridCell{X: int(math.Floor(x)), Y: int(math.Floor(y))}
+}
+
+func tileCenter(c gridCell) pathPoint {
+	return pathPoint{X: float64(c.X) + 0.5, Y: float64(c.Y) + 0.5}
+}
+
+func octile(a, b gridCell) float64 {
+	dx := math.Abs(float64(a.X - b.X))
+	dy := math.Abs(float64(a.Y - b.Y))
+	return math.Max(dx, dy) + (math.Sqrt2-1)*math.Min(dx, dy)
+}
+
+// findPath runs A* over tile centres. Tiles must be walkable for the given
+// collision radius and accepted by allowed. When the goal cannot be reached
+// the path leads to the closest reachable tile instead, and ok is false.
+func (z *zone) findPath(fromX, fromY, toX, toY, radius float64, allowed func(x, y float64) bool) ([]pathPoint, bool) {
+	if z.hasLineOfSight(fromX, fromY, toX, toY, radius) && allowed(toX, toY) {
+		return []pathPoint{{X: toX, Y: toY}}, true
+	}
+
+	start := tileOf(fromX, fromY)
+	goal := tileOf(toX, toY)
+	passable := func(c gridCell) bool {
+		p := tileCenter(c)
+		return z.isWalkableWithRadius(p.X, p.Y, radius) && allowed(p.X, p.Y)
+	}
+
+	open := &pathQueue{}
+	nodes := map[gridCell]*pathNode{start: {cell: start, f: octile(start, goal)}}
+	heap.Push(open, nodes[start])
+	closed := make(map[gridCell]bool)
+	best := nodes[start]
+	bestH := octile(start, goal)
+
+	for open.Len() > 0 && len(closed) < pathMaxExpanded {
+		cur := heap.Pop(open).(*pathNode)
+		if cur.cell == goal {
+			best = cur
+			bestH = 0
+			break
+		}
+		closed[cur.cell] = true
+		if h := octile(cur.cell, goal); h < bestH {
+			best, bestH = cur, h
+		}
+		for _, d := range pathNeighbours {
+			next := gridCell{X: cur.cell.X + d[0], Y: cur.cell.Y + d[1]}
+			if closed[next] || !passable(next) {
+				continue
+			}
+			cost := 1.0
+			if d[0] != 0 && d[1] != 0 {
+				// No corner cutting: both orthogonal neighbours must be open.
+				if !passable(gridCell{X: cur.cell.X + d[0], Y: cur.cell.Y}) || !passable(gridCell{X: cur.cell.X, Y: cur.cell.Y + d[1]}) {
+					continue
+				}
+				cost = math.Sqrt2
+			}
+			g := cur.g + cost
+			n, seen := nodes[next]
+			if seen && g >= n.g {
+				continue
+			}
+			if !seen {
+				n = &pathNode{cell: next}
+				nodes[next] = n
+			}
+			n.g = g
+			n.f = g + octile(next, goal)
+			n.parent = cur
+			if seen && n.index >= 0 {
+				heap.Fix(open, n.index)
+			} else {
+				heap.Push(open, n)
+			}
+		}
+	}
+
+	reached := bestH == 0
+	path := make([]pathPoint, 0)
+	for n := best; n != nil && n.cell != start; n = n.parent {
+		path = append(path, tileCenter(n.cell))
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	if reached && allowed(toX, toY) && z.isWalkableWithRadius(toX, toY, radius) {
+		if len(path) > 0 {
+			path[len(path)-1] = pathPoint{X: toX, Y: toY}
+		} else {
+			path = append(path, pathPoint{X: toX, Y: toY})
+		}
+	}
+	return path, reached
+}
+
+func (z *zone) hasLineOfSight(ax, ay, bx, by, radius float64) bool {
+	d := distance(ax, ay, bx, by)
+	steps := int(math.Ceil(d / lineOfSightStep))
+	for i := 1; i <= steps; i++ {
+		t := float64(i) / float64(steps)
+		if !z.isWalkableWithRadius(ax+(bx-ax)*t, ay+(by-ay)*t, radius) {
+			return false
+		}
+	}
+	return true
+}
+
+// moveMobAlongPathLocked advances a mob one tick towards (x, y), reusing its
+// cached path until it goes stale or the goal drifts too far.
+func (z *zone) moveMobAlongPathLocked(mob *mobRuntime, x, y float64, allowed func(x, y float64) bool) {
+	stale := mob.Path == nil || mob.PathAge >= pathReplanTicks ||
+		distance(mob.PathGoal.X, mob.PathGoal.Y, x, y) > pathGoalDrift
+	if stale {
+		mob.Path, _ = z.findPath(mob.State.X, mob.State.Y, x, y, mobCollisionRadius, allowed)
+		mob.PathGoal = pathPoint{X: x, Y: y}
+		mob.PathAge = 0
+	}
+	mob.PathAge++
+
+	budget := mob.moveSpeed()
+	for budget > 1e-9 && len(mob.Path) > 0 {
+		wp := mob.Path[0]
+		d := distance(mob.State.X, mob.State.Y, wp.X, wp.Y)
+		if d <= budget {
+			if !z.isWalkableWithRadius(wp.X, wp.Y, mobCollisionRadius) {
+				mob.Path = nil
+				return
+			}
+			mob.State.X, mob.State.Y = wp.X, wp.Y
+			mob.Path = mob.Path[1:]
+			budget -= d
+			continue
+		}
+		nx := mob.State.X + (wp.X-mob.State.X)/d*budget
+		ny := mob.State.Y + (wp.Y-mob.State.Y)/d*budget
+		if !z.isWalkableWithRadius(nx, ny, mobCollisionRadius) {
+			mob.Path = nil
+			return
+		}
+		mob.State.X, mob.State.Y = nx, ny
+		return
+	}
+}
+
+func (mob *mobRuntime) clearPath() {
+	mob.Path = nil
+	mob.PathAge = 0
+}
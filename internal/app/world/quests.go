@@ -0,0 +1,241 @@
+package world
+
+import (
+	"fmt"
+	"sort"
+
+	"mmorp-server/internal/domain/character"
+	domainworld "mmorp-server/internal/domain/world"
+)
+
+const (
+	npcActionAccept  = "accept"
+	npcActionAbandon = "abandon"
+	npcActionTurnIn  = "turn_in"
+	maxActiveQuests  = 20
+
+	questViewAvailable = "available"
+	questViewReady     = "ready"
+)
+
+func restoreQuestLog(saved []character.QuestProgress, quests map[string]domainworld.Quest) map[string]*character.QuestProgress {
+	log := make(map[string]*character.QuestProgress, len(saved))
+	for _, qp := range saved {
+		q, ok := quests[qp.QuestID]
+		if !ok || (qp.Status != character.QuestStatusActive && qp.Status != character.QuestStatusCompleted) {
+			continue
+		}
+		progress := make([]int, len(q.Objectives))
+		if qp.Status == character.QuestStatusActive {
+			copy(progress, qp.Progress)
+		}
+		log[qp.QuestID] = &character.QuestProgress{QuestID: qp.QuestID, Status: qp.Status, Progress: progress}
+	}
+	return log
+}
+
+func questLogSlice(log map[string]*character.QuestProgress) []character.QuestProgress {
+	out := make([]character.QuestProgress, 0, len(log))
+	for _, qp := range log {
+		out = append(out, character.QuestProgress{QuestID: qp.QuestID, Status: qp.Status, Progress: append([]int(nil), qp.Progress...)})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].QuestID < out[j].QuestID })
+	return out
+}
+
+// objectiveProgress reports progress on one objective. Collect objectives
+// are read from the inventory rather than tracked.
+func objectiveProgress(pr *playerRuntime, q domainworld.Quest, entry *character.QuestProgress, i int) int {
+	o := q.Objectives[i]
+	if o.Type == domainworld.QuestObjectiveCollect {
+		return min(pr.Inventory.Count(o.Target), o.Count)
+	}
+	return entry.Progress[i]
+}
+
+func questReady(pr *playerRuntime, q domainworld.Quest, entry *character.QuestProgress) bool {
+	for i, o := range q.Objectives {
+		if objectiveProgress(pr, q, entry, i) < o.Count {
+			return false
+		}
+	}
+	return true
+}
+
+// questStatus is the player's status for q as shown to clients: available,
+// active, ready (active with every objective met) or completed.
+func questStatus(pr *playerRuntime, q domainworld.Quest) string {
+	entry := pr.Quests[q.ID]
+	switch {
+	case entry == nil:
+		return questViewAvailable
+	case entry.Status == character.QuestStatusActive && questReady(pr, q, entry):
+		return questViewReady
+	default:
+		return entry.Status
+	}
+}
+
+func questView(pr *playerRuntime, q domainworld.Quest) map[string]any {
+	entry := pr.Quests[q.ID]
+	objectives := make([]map[string]any, 0, len(q.Objectives))
+	for i, o := range q.Objectives {
+		progress := 0
+		if entry != nil && entry.Status == character.QuestStatusActive {
+			progress = objectiveProgress(pr, q, entry, i)
+		}
+		objectives = append(objectives, map[string]any{"type": o.Type, "target": o.Target, "count": o.Count, "progress": progress})
+	}
+	return map[string]any{
+		"id":          q.ID,
+		"name":        q.Name,
+		"description": q.Description,
+		"status":      questStatus(pr, q),
+		"turn_in":     q.TurnInID,
+		"objectives":  objectives,
+		"rewards":     q.Rewards,
+	}
+}
+
+func (s *Service) questLogMessage(pr *playerRuntime) map[string]any {
+	ids := make([]string, 0, len(pr.Quests))
+	for id, entry := range pr.Quests {
+		if entry.Status == character.QuestStatusActive {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	views := make([]map[string]any, 0, len(ids))
+	for _, id := range ids {
+		views = append(views, questView(pr, s.quests[id]))
+	}
+	return map[string]any{"type": "quest_log", "quests": views}
+}
+
+// npcQuestsLocked lists what an NPC offers the player: quests it gives that
+// the player can take, and active quests that are handed in to it.
+func (s *Service) npcQuestsLocked(pr *playerRuntime, npcID string) []map[string]any {
+	ids := make([]string, 0)
+	for id, q := range s.quests {
+		entry := pr.Quests[id]
+		switch {
+		case entry == nil && q.GiverID == npcID && pr.State.Level >= q.MinLevel:
+		case entry != nil && entry.Status == character.QuestStatusActive && (q.GiverID == npcID || q.TurnInID == npcID):
+		default:
+			continue
+		}
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	views := make([]map[string]any, 0, len(ids))
+	for _, id := range ids {
+		views = append(views, questView(pr, s.quests[id]))
+	}
+	return views
+}
+
+func (s *Service) acceptQuestLocked(pr *playerRuntime, npc *domainworld.NPC, questID string) (map[string]any, error) {
+	q, ok := s.quests[questID]
+	if !ok || q.GiverID != npc.ID {
+		return nil, fmt.Errorf("%s does not offer that quest", npc.Name)
+	}
+	if entry := pr.Quests[questID]; entry != nil {
+		return nil, fmt.Errorf("quest already %s", entry.Status)
+	}
+	if pr.State.Level < q.MinLevel {
+		return nil, fmt.Errorf("requires level %d", q.MinLevel)
+	}
+	active := 0
+	for _, entry := range pr.Quests {
+		if entry.Status == character.QuestStatusActive {
+			active++
+		}
+	}
+	if active >= maxActiveQuests {
+		return nil, fmt.Errorf("quest log is full")
+	}
+	pr.Quests[questID] = &character.QuestProgress{QuestID: questID, Status: character.QuestStatusActive, Progress: make([]int, len(q.Objectives))}
+	return map[string]any{"success": true, "quest": questView(pr, q)}, nil
+}
+
+func (s *Service) abandonQuestLocked(pr *playerRuntime, questID string) (map[string]any, error) {
+	entry := pr.Quests[questID]
+	if entry == nil || entry.Status != character.QuestStatusActive {
+		return nil, fmt.Errorf("quest is not active")
+	}
+	delete(pr.Quests, questID)
+	return map[string]any{"success": true, "quest_id": questID}, nil
+}
+
+// turnInQuestLocked checks every objective and that the reward items fit
+// before consuming collected items and paying out.
+func (s *Service) turnInQuestLocked(pr *playerRuntime, npc *domainworld.NPC, questID string) (map[string]any, error) {
+	q, ok := s.quests[questID]
+	entry := pr.Quests[questID]
+	if !ok || entry == nil || entry.Status != character.QuestStatusActive {
+		return nil, fmt.Errorf("quest is not active")
+	}
+	if q.TurnInID != npc.ID {
+		return nil, fmt.Errorf("%s is not expecting that quest", npc.Name)
+	}
+	if !questReady(pr, q, entry) {
+		return nil, fmt.Errorf("quest objectives are not complete")
+	}
+	for _, r := range q.Rewards.Items {
+		if pr.Inventory.Room(r.ItemID) < r.Quantity {
+			return nil, fmt.Errorf("inventory full")
+		}
+	}
+	for _, o := range q.Objectives {
+		if o.Type == domainworld.QuestObjectiveCollect {
+			pr.Inventory.Remove(o.Target, o.Count)
+		}
+	}
+	for _, r := range q.Rewards.Items {
+		pr.Inventory.Add(r.ItemID, r.Quantity)
+	}
+	pr.State.Gold += q.Rewards.Gold
+	s.grantXPLocked(pr, q.Rewards.XP)
+	entry.Status = character.QuestStatusCompleted
+	entry.Progress = make([]int, len(q.Objectives))
+	return map[string]any{"success": true, "quest_id": questID, "rewards": q.Rewards}, nil
+}
+
+// recordObjectiveLocked advances every active objective of the given type and
+// target, returning a quest_progress message per change.
+func (s *Service) recordObjectiveLocked(pr *playerRuntime, kind domainworld.QuestObjectiveType, target string) []map[string]any {
+	updates := make([]map[string]any, 0)
+	for id, entry := range pr.Quests {
+		if entry.Status != character.QuestStatusActive {
+			continue
+		}
+		q := s.quests[id]
+		for i, o := range q.Objectives {
+			if o.Type != kind || o.Target != target || entry.Progress[i] >= o.Count {
+				continue
+			}
+			entry.Progress[i]++
+			updates = append(updates, map[string]any{
+				"type":      "quest_progress",
+				"quest_id":  id,
+				"objective": i,
+				"progress":  entry.Progress[i],
+				"count":     o.Count,
+				"ready":     questReady(pr, q, entry),
+			})
+		}
+	}
+	return updates
+}
+
+func (s *Service) QuestLog(c *Client) {
+	z := s.zoneOf(c.CharacterID)
+	if z == nil {
+		return
+	}
+	z.mu.RLock()
+	defer z.mu.RUnlock()
+	if pr, ok := z.players[c.CharacterID]; ok {
+		nonBlockingSendJSON(c.Send, s.questLogMessage(pr))
+	}
+}
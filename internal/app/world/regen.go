@@ -0,0 +1,128 @@
+package world
+
+import "fmt"
+
+const (
+	// combatLingerTicks is how long after last dealing or taking damage a
+	// player still counts as in combat.
+	combatLingerTicks   = 50
+	restRegenMultiplier = 3
+	mealTicks           = 200
+)
+
+// mealState is food being eaten: extra healing each second while the player
+// keeps resting, until UntilTick.
+type mealState struct {
+	HealPerSecond int
+	UntilTick     uint64
+}
+
+func (z *zone) inCombatLocked(pr *playerRuntime) bool {
+	return pr.LastCombatTick > 0 && z.tick < pr.LastCombatTick+combatLingerTicks
+}
+
+// enterCombatLocked marks the player as fighting, which stops regeneration
+// and gets them up if they were resting.
+func (z *zone) enterCombatLocked(pr *playerRuntime) []zoneEvent {
+	pr.LastCombatTick = z.tick
+	return standUpLocked(pr)
+}
+
+// standUpLocked ends a player's rest, and any meal with it.
+func standUpLocked(pr *playerRuntime) []zoneEvent {
+	if !pr.State.Resting {
+		return nil
+	}
+	pr.State.Resting = false
+	pr.Meal = nil
+	return []zoneEvent{restingEvent(pr)}
+}
+
+func restingEvent(pr *playerRuntime) zoneEvent {
+	return zoneEvent{X: pr.State.X, Y: pr.State.Y, Payload: map[string]any{"type": "player_resting", "player_id": pr.State.ID, "resting": pr.State.Resting}}
+}
+
+// rebaseTick moves a past tick from one zone's counter to another's. Ticks
+// further back than the new zone has run become zero.
+func rebaseTick(t, fromTick, toTick uint64) uint64 {
+	if t == 0 || fromTick-t >= toTick {
+		return 0
+	}
+	return toTick - (fromTick - t)
+}
+
+// Rest sits the player down. Resting multiplies HP regeneration until they
+// move, attack, cast or are hit.
+func (s *Service) Rest(c *Client) {
+	z := s.zoneOf(c.CharacterID)
+	if z == nil {
+		return
+	}
+	z.mu.Lock()
+	pr, ok := z.players[c.CharacterID]
+	if !ok {
+		z.mu.Unlock()
+		return
+	}
+	events, err := z.restLocked(pr)
+	z.mu.Unlock()
+
+	if err != nil {
+		nonBlockingSendJSON(c.Send, map[string]any{"type": "error", "message": err.Error()})
+		return
+	}
+	z.dispatch(events)
+}
+
+func (z *zone) restLocked(pr *playerRuntime) ([]zoneEvent, error) {
+	switch {
+	case pr.State.HP <= 0:
+		return nil, fmt.Errorf("you are dead")
+	case z.inCombatLocked(pr):
+		return nil, fmt.Errorf("you cannot rest in combat")
+	case pr.Cast != nil:
+		return nil, fmt.Errorf("already casting")
+	case pr.State.Resting:
+		return nil, nil
+	}
+	pr.State.Resting = true
+	pr.PendingMove = nil
+	return []zoneEvent{restingEvent(pr)}, nil
+}
+
+// stepRegenLocked refills resources and, out of combat, HP once a second,
+// and sends each player whose values changed a player_update.
+func (s *Service) stepRegenLocked(z *zone) {
+	if s.tickRate <= 0 || z.tick%uint64(s.tickRate) != 0 {
+		return
+	}
+	for _, pr := range z.players {
+		if pr.State.HP <= 0 {
+			continue
+		}
+		changed := false
+		if pr.State.Resource < pr.State.MaxResource {
+			pr.State.Resource = min(pr.State.MaxResource, pr.State.Resource+pr.Class.ResourceRegen)
+			changed = true
+		}
+		if pr.Meal != nil && z.tick > pr.Meal.UntilTick {
+			pr.Meal = nil
+		}
+		if pr.State.HP < pr.State.MaxHP && !z.inCombatLocked(pr) {
+			amount := pr.State.Stats.HPRegen
+			if pr.State.Resting {
+				amount *= restRegenMultiplier
+				if pr.Meal != nil {
+					amount += pr.Meal.HealPerSecond
+				}
+			}
+			if amount > 0 {
+				pr.State.HP = min(pr.State.MaxHP, pr.State.HP+amount)
+				changed = true
+			}
+		}
+		if changed {
+			nonBlockingSendJSON(pr.Client.Send, map[string]any{"type": "player_update", "player": pr.State})
+		}
+	}
+}
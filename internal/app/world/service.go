@@ -3,12 +3,14 @@ package world
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"math"
-	"math/rand"
-	"os"
+	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
@@ -21,132 +23,231 @@ import (
 )
 
 const (
-	playerMoveSpeed        = 0.35
-	playerCollisionRadius  = 0.2
-	playerAttackRange      = 1.3
-	basePlayerDamage       = 20
-	mobAggroRange          = 6.0
-	mobAttackRange         = 1.1
-	mobMoveSpeed           = 0.18
-	mobAttackCooldownTicks = 7
-	mobRespawnTicks        = 50
-	mobWanderMaxTicks      = 20
-	positionUpdateTimeout  = 8 * time.Second
-	positionUpdateRetries  = 3
-	positionRetryBackoff   = 250 * time.Millisecond
+	playerMoveSpeed       = 0.35
+	playerCollisionRadius = 0.2
+	mobWanderMaxTicks     = 20
+	positionUpdateTimeout = 8 * time.Second
+	positionUpdateRetries = 3
+	positionRetryBackoff  = 250 * time.Millisecond
+	// positionSaveTicks is the least time between two position saves for a
+	// moving player; logout and zone changes always save.
+	positionSaveTicks = 50
 )
 
 type CharacterPositionUpdater interface {
 	UpdatePosition(ctx context.Context, userID, characterID uuid.UUID, x, y float64, zoneID string) error
 }
 
-type Client struct {
-	Conn        *websocket.Conn
-	AccountID   uuid.UUID
-	CharacterID uuid.UUID
-	Send        chan []byte
+// CharacterSaver writes progress, inventory, equipment and quests together,
+// so a trade or a quest reward can never be saved only in part.
+type CharacterSaver interface {
+	SaveCharacter(ctx context.Context, userID, characterID uuid.UUID, progress character.Progress, slots []character.InventorySlot, equipped []character.EquippedItem, quests []character.QuestProgress) error
 }
 
-type MapJSON struct {
-	Width  int                    `json:"width"`
-	Height int                    `json:"height"`
-	Spawn  domainworld.SpawnPoint `json:"spawn"`
-	Rows   []string               `json:"rows"`
-	NPCs   []NPCJSON              `json:"npcs"`
-	Mobs   []MobJSON              `json:"mobs"`
+type CharacterInventoryStore interface {
+	LoadInventory(ctx context.Context, userID, characterID uuid.UUID) ([]character.InventorySlot, error)
+	LoadEquipment(ctx context.Context, userID, characterID uuid.UUID) ([]character.EquippedItem, error)
 }
 
-type NPCJSON struct {
-	ID           string   `json:"id"`
-	Name         string   `json:"name"`
-	Role         string   `json:"role"`
-	Interactions []string `json:"interactions"`
-	Dialogue     string   `json:"dialogue"`
-	TradeItems   []string `json:"trade_items"`
-	QuestInfo    string   `json:"quest_info"`
-	GoldPrice    int      `json:"gold_price"`
-	X            float64  `json:"x"`
-	Y            float64  `json:"y"`
+type CharacterQuestStore interface {
+	LoadQuests(ctx context.Context, userID, characterID uuid.UUID) ([]character.QuestProgress, error)
 }
 
-type MobJSON struct {
-	ID           string  `json:"id"`
-	Name         string  `json:"name"`
-	X            float64 `json:"x"`
-	Y            float64 `json:"y"`
-	HP           int     `json:"hp"`
-	Damage       int     `json:"damage"`
-	PatrolRadius float64 `json:"patrol_radius"`
+type CharacterStore interface {
+	CharacterPositionUpdater
+	CharacterSaver
+	CharacterInventoryStore
+	CharacterQuestStore
 }
 
-type playerRuntime struct {
-	State domainworld.PlayerState
+type Client struct {
+	Conn        *websocket.Conn
+	AccountID   uuid.UUID
+	CharacterID uuid.UUID
+	Send        chan []byte
+
+	lastInputSeq atomic.Uint64
+	saveSeq      atomic.Uint64
+	saveMu       sync.Mutex
+	savedSeq     uint64
+	chat         chatLimiter
 }
 
-type mobRuntime struct {
-	State             domainworld.MobState
-	SpawnX            float64
-	SpawnY            float64
-	AttackCooldown    int
-	RespawnCounter    int
-	WanderDX          float64
-	WanderDY          float64
-	WanderTicksRemain int
+func (c *Client) LastProcessedSeq() uint64 {
+	return c.lastInputSeq.Load()
 }
 
 type Service struct {
-	logger   zerolog.Logger
-	pub      mq.Publisher
-	updater  CharacterPositionUpdater
-	zoneID   string
-	tickRate int
-
-	mu       sync.RWMutex
-	clients  map[*Client]struct{}
-	players  map[uuid.UUID]*playerRuntime
-	mobs     map[string]*mobRuntime
-	npcs     []domainworld.NPC
-	worldMap domainworld.TileMap
-	tick     uint64
-	quit     chan struct{}
-	started  bool
-	rand     *rand.Rand
-}
-
-type zoneEvent struct {
-	ZoneID  string
-	Payload any
-}
-
-func NewService(logger zerolog.Logger, pub mq.Publisher, updater CharacterPositionUpdater, zoneID string, tickRate int, mapFile string) *Service {
-	worldMap, npcs, mobs, err := loadWorldMap(mapFile, zoneID)
+	logger        zerolog.Logger
+	pub           mq.Publisher
+	store         CharacterStore
+	defaultZoneID string
+	tickRate      int
+	zones         map[string]*zone
+	items         map[string]domainworld.Item
+	quests        map[string]domainworld.Quest
+	dialogues     map[string]domainworld.Dialogue
+	chatFilter    ChatFilter
+	abilities     map[string]domainworld.Ability
+	classes       character.ClassCatalog
+	death         DeathRules
+
+	// socialMu guards the online directory and parties. It is taken after
+	// any zone lock.
+	socialMu      sync.Mutex
+	online        map[uuid.UUID]partyMember
+	playerParties map[uuid.UUID]*party
+	partyInvites  map[uuid.UUID]partyInvite
+	partySeq      int
+
+	mu          sync.RWMutex
+	clients     map[*Client]struct{}
+	playerZones map[uuid.UUID]*zone
+	quit        chan struct{}
+	started     bool
+}
+
+func NewService(logger zerolog.Logger, pub mq.Publisher, store CharacterStore, defaultZoneID string, tickRate int, dataDir string) *Service {
+	effects, err := loadEffects(filepath.Join(dataDir, "effects.json"))
+	if err != nil {
+		logger.Warn().Err(err).Str("data_dir", dataDir).Msg("failed to load effect catalog")
+		effects = map[string]domainworld.StatusEffect{}
+	}
+	items, err := loadItems(filepath.Join(dataDir, "items.json"))
+	if err != nil {
+		logger.Warn().Err(err).Str("data_dir", dataDir).Msg("failed to load item catalog")
+		items = map[string]domainworld.Item{}
+	}
+	templates, err := loadMobTemplates(filepath.Join(dataDir, "mobs.json"))
+	if err != nil {
+		logger.Warn().Err(err).Str("data_dir", dataDir).Msg("failed to load mob templates")
+	}
+	for _, t := range templates {
+		for _, d := range t.Loot.Drops {
+			if _, ok := items[d.ItemID]; d.ItemID != "" && !ok {
+				logger.Warn().Str("template", t.ID).Str("item_id", d.ItemID).Msg("loot table drops unknown item")
+			}
+		}
+	}
+	cat := catalog{items: items, mobs: templates}
+	quests, err := loadQuests(filepath.Join(dataDir, "quests.json"), cat)
+	if err != nil {
+		logger.Warn().Err(err).Str("data_dir", dataDir).Msg("failed to load quest catalog")
+		quests = map[string]domainworld.Quest{}
+	}
+	cat.quests = quests
+	dialogues, err := loadDialogues(filepath.Join(dataDir, "dialogues.json"), cat)
+	if err != nil {
+		logger.Warn().Err(err).Str("data_dir", dataDir).Msg("failed to load dialogues")
+		dialogues = map[string]domainworld.Dialogue{}
+	}
+	cat.dialogues = dialogues
+	abilities, err := loadAbilities(filepath.Join(dataDir, "abilities.json"))
+	if err != nil {
+		logger.Warn().Err(err).Str("data_dir", dataDir).Msg("failed to load ability catalog")
+		abilities = map[string]domainworld.Ability{}
+	}
+	classes, err := loadClasses(filepath.Join(dataDir, "classes.json"))
+	if err != nil {
+		logger.Warn().Err(err).Str("data_dir", dataDir).Msg("failed to load class catalog, using the default class")
+		classes = fallbackClasses()
+	}
+	for _, cls := range classes {
+		for _, id := range cls.Abilities {
+			if _, ok := abilities[id]; !ok {
+				logger.Warn().Str("class", cls.ID).Str("ability_id", id).Msg("class lists unknown ability")
+			}
+		}
+	}
+	effectRefs := make(map[string][]string)
+	for _, it := range items {
+		effectRefs["item "+it.ID] = it.Effects
+	}
+	for _, t := range templates {
+		effectRefs["mob template "+t.ID] = t.Effects
+		for _, a := range t.Attacks {
+			effectRefs["mob attack "+t.ID+"/"+a.ID] = a.Effects
+		}
+	}
+	for _, a := range abilities {
+		effectRefs["ability "+a.ID] = a.Effects
+	}
+	for owner, ids := range effectRefs {
+		for _, id := range ids {
+			if _, ok := effects[id]; !ok {
+				logger.Warn().Str("owner", owner).Str("effect_id", id).Msg("unknown status effect")
+			}
+		}
+	}
+	chatFilter, err := loadChatFilter(filepath.Join(dataDir, "chat.json"))
+	if err != nil {
+		logger.Warn().Err(err).Str("data_dir", dataDir).Msg("failed to load chat blocklist")
+	}
+	death, err := loadDeathRules(filepath.Join(dataDir, "death.json"))
+	if err != nil {
+		logger.Warn().Err(err).Str("data_dir", dataDir).Msg("failed to load death rules, using defaults")
+	}
+	mapDir := filepath.Join(dataDir, "maps")
+	loaded, err := loadZoneDir(mapDir, cat)
 	if err != nil {
-		logger.Warn().Err(err).Str("map_file", mapFile).Msg("failed to load world map file, using fallback")
-		worldMap, npcs, mobs = fallbackWorld(zoneID)
+		logger.Warn().Err(err).Str("map_dir", mapDir).Msg("failed to load world maps, using fallback")
+		loaded = nil
+	}
+	zones := make(map[string]*zone, len(loaded)+1)
+	for _, zd := range loaded {
+		zones[zd.ID] = newZone(logger, zd, effects)
+	}
+	if _, ok := zones[defaultZoneID]; !ok {
+		logger.Warn().Str("zone_id", defaultZoneID).Str("map_dir", mapDir).Msg("default zone has no map file, using fallback")
+		zones[defaultZoneID] = newZone(logger, fallbackWorld(defaultZoneID), effects)
 	}
-	mobState := make(map[string]*mobRuntime, len(mobs))
-	for i := range mobs {
-		m := mobs[i]
-		mobState[m.ID] = &mobRuntime{
-			State:  m,
-			SpawnX: m.X,
-			SpawnY: m.Y,
+	for _, z := range zones {
+		for _, p := range z.worldMap.Portals {
+			if _, ok := zones[p.TargetZoneID]; !ok {
+				logger.Warn().Str("zone_id", z.id).Str("portal_id", p.ID).Str("target_zone", p.TargetZoneID).Msg("portal targets unknown zone")
+			}
+		}
+	}
+	npcs := make(map[string]struct{})
+	for _, z := range zones {
+		for _, npc := range z.npcs {
+			npcs[npc.ID] = struct{}{}
+		}
+	}
+	for _, q := range quests {
+		refs := []string{q.GiverID, q.TurnInID}
+		for _, o := range q.Objectives {
+			if o.Type == domainworld.QuestObjectiveTalk {
+				refs = append(refs, o.Target)
+			}
+		}
+		for _, id := range refs {
+			if _, ok := npcs[id]; !ok {
+				logger.Warn().Str("quest_id", q.ID).Str("npc_id", id).Msg("quest references unknown npc")
+			}
 		}
 	}
 
 	return &Service{
-		logger:   logger,
-		pub:      pub,
-		updater:  updater,
-		zoneID:   zoneID,
-		tickRate: tickRate,
-		clients:  make(map[*Client]struct{}),
-		players:  make(map[uuid.UUID]*playerRuntime),
-		mobs:     mobState,
-		npcs:     npcs,
-		worldMap: worldMap,
-		quit:     make(chan struct{}),
-		rand:     rand.New(rand.NewSource(time.Now().UnixNano())),
+		logger:        logger,
+		pub:           pub,
+		store:         store,
+		defaultZoneID: defaultZoneID,
+		tickRate:      tickRate,
+		zones:         zones,
+		items:         items,
+		quests:        quests,
+		dialogues:     dialogues,
+		chatFilter:    chatFilter,
+		abilities:     abilities,
+		classes:       classes,
+		death:         death,
+		clients:       make(map[*Client]struct{}),
+		playerZones:   make(map[uuid.UUID]*zone),
+		quit:          make(chan struct{}),
+		online:        make(map[uuid.UUID]partyMember),
+		playerParties: make(map[uuid.UUID]*party),
+		partyInvites:  make(map[uuid.UUID]partyInvite),
 	}
 }
 
@@ -160,18 +261,82 @@ func (s *Service) Start() {
 	s.mu.Unlock()
 
 	interval := time.Second / time.Duration(s.tickRate)
+	for _, z := range s.zones {
+		go s.runZone(z, interval)
+	}
+}
+
+func (s *Service) runZone(z *zone, interval time.Duration) {
 	ticker := time.NewTicker(interval)
-	go func() {
-		defer ticker.Stop()
-		for {
-			select {
-			case <-ticker.C:
-				s.tickWorld()
-			case <-s.quit:
-				return
-			}
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.tickZone(z)
+		case <-s.quit:
+			return
 		}
-	}()
+	}
+}
+
+func (s *Service) tickZone(z *zone) {
+	z.mu.Lock()
+	z.tick++
+	tick := z.tick
+	moved := z.applyMovementLocked()
+	events := z.stepMobsLocked()
+	events = append(events, s.stepEffectsLocked(z)...)
+	events = append(events, s.stepCastsLocked(z)...)
+	s.stepRegenLocked(z)
+	events = append(events, z.expireLootLocked()...)
+	for id, mob := range z.mobs {
+		z.mobGrid.Upsert(id, mob.State.X, mob.State.Y)
+	}
+	for _, pr := range z.players {
+		for _, evt := range z.refreshInterestLocked(pr) {
+			nonBlockingSendJSON(pr.Client.Send, evt)
+		}
+		snapshot := z.mobSnapshotLocked(pr)
+		if snapshot == nil {
+			continue
+		}
+		if !nonBlockingSendJSON(pr.Client.Send, snapshot) {
+			// The client missed this frame, so its view no longer matches
+			// the baseline; resync with a keyframe next tick.
+			pr.MobBaseline = nil
+		}
+	}
+	z.mu.Unlock()
+
+	for _, m := range moved {
+		if m.Portal != nil && s.transferPlayer(m.Client, z, *m.Portal) {
+			continue
+		}
+		z.sendToPlayer(m.State.ID, map[string]any{
+			"type":      "player_moved",
+			"player_id": m.State.ID,
+			"x":         m.State.X,
+			"y":         m.State.Y,
+			"seq":       m.Seq,
+			"tick":      m.Tick,
+		})
+		if !m.Changed {
+			continue
+		}
+		z.broadcastNear(m.State.ID, m.State.X, m.State.Y, map[string]any{
+			"type":      "player_moved",
+			"player_id": m.State.ID,
+			"x":         m.State.X,
+			"y":         m.State.Y,
+		})
+		if m.Persist {
+			s.persistPositionAsync(m.Client, m.State.X, m.State.Y, m.State.ZoneID)
+		}
+	}
+	z.dispatch(events)
+	if tick%partyFrameTicks == 0 {
+		s.sendPartyFrames(z)
+	}
 }
 
 func (s *Service) Stop() {
@@ -187,9 +352,38 @@ func (s *Service) Stop() {
 		clients = append(clients, c)
 	}
 	s.clients = map[*Client]struct{}{}
-	s.players = map[uuid.UUID]*playerRuntime{}
+	s.playerZones = map[uuid.UUID]*zone{}
 	s.mu.Unlock()
 
+	s.socialMu.Lock()
+	s.online = map[uuid.UUID]partyMember{}
+	s.playerParties = map[uuid.UUID]*party{}
+	s.partyInvites = map[uuid.UUID]partyInvite{}
+	s.socialMu.Unlock()
+
+	players := make([]*playerRuntime, 0)
+	saves := make(map[uuid.UUID]playerSave)
+	for _, z := range s.zones {
+		z.mu.Lock()
+		for _, pr := range z.players {
+			// Shutting down releases the dead the same way logging out
+			// does, so a restart is not a free resurrection.
+			if pr.State.Dead {
+				s.respawnLocked(z, pr)
+			}
+			players = append(players, pr)
+			saves[pr.State.ID] = snapshotPlayerLocked(pr)
+		}
+		z.players = map[uuid.UUID]*playerRuntime{}
+		z.playerGrid = newSpatialGrid[uuid.UUID](interestCellSize)
+		z.mu.Unlock()
+	}
+
+	for _, pr := range players {
+		ctx, cancel := context.WithTimeout(context.Background(), positionUpdateTimeout)
+		s.savePlayer(ctx, pr.Client, saves[pr.State.ID])
+		cancel()
+	}
 	for _, c := range clients {
 		close(c.Send)
 		if c.Conn != nil {
@@ -209,578 +403,471 @@ func (s *Service) RegisterClient(conn *websocket.Conn, accountID uuid.UUID) *Cli
 func (s *Service) UnregisterClient(ctx context.Context, c *Client) {
 	s.mu.Lock()
 	delete(s.clients, c)
-	pr, exists := s.players[c.CharacterID]
-	if exists {
-		delete(s.players, c.CharacterID)
+	z, inZone := s.playerZones[c.CharacterID]
+	if inZone {
+		delete(s.playerZones, c.CharacterID)
 	}
 	s.mu.Unlock()
 
-	if exists {
-		s.broadcastZone(c.CharacterID, pr.State.ZoneID, map[string]any{"type": "player_left", "player_id": c.CharacterID})
-		s.broadcastZone(uuid.Nil, pr.State.ZoneID, map[string]any{"type": "broadcast", "message": fmt.Sprintf("%s left the world", pr.State.Name)})
-		if s.updater != nil {
-			if err := s.updater.UpdatePosition(ctx, c.AccountID, c.CharacterID, pr.State.X, pr.State.Y, pr.State.ZoneID); err != nil {
-				s.logger.Warn().Err(err).Str("character_id", c.CharacterID.String()).Msg("failed to persist position")
-			}
+	if inZone {
+		z.mu.Lock()
+		// Logging out while dead releases the spirit, so the corpse cannot
+		// be left behind to revive at for free.
+		if pr, ok := z.players[c.CharacterID]; ok && pr.State.Dead {
+			s.respawnLocked(z, pr)
+		}
+		pr, observers := z.removePlayerLocked(c.CharacterID)
+		var save playerSave
+		if pr != nil {
+			z.sendLocked(observers, map[string]any{"type": "player_left", "player_id": c.CharacterID})
+			save = snapshotPlayerLocked(pr)
+		}
+		z.mu.Unlock()
+
+		if pr != nil {
+			z.broadcast(uuid.Nil, map[string]any{"type": "broadcast", "message": fmt.Sprintf("%s left the world", pr.State.Name)})
+			s.savePlayer(ctx, c, save)
 		}
 	}
+	s.removeOnline(c.CharacterID)
 	close(c.Send)
 	if c.Conn != nil {
 		_ = c.Conn.Close()
 	}
 }
 
-func (s *Service) Join(c *Client, char character.Character) {
+// playerSave is a point-in-time copy of everything persisted for a player.
+// seq orders snapshots so an older one never overwrites a newer one.
+type playerSave struct {
+	seq    uint64
+	state  domainworld.PlayerState
+	slots  []character.InventorySlot
+	quests []character.QuestProgress
+}
+
+func snapshotPlayerLocked(pr *playerRuntime) playerSave {
+	return playerSave{seq: pr.Client.saveSeq.Add(1), state: pr.State, slots: pr.Inventory.Slots(), quests: questLogSlice(pr.Quests)}
+}
+
+func (s *Service) savePlayer(ctx context.Context, c *Client, save playerSave) error {
+	c.saveMu.Lock()
+	defer c.saveMu.Unlock()
+	if save.seq <= c.savedSeq {
+		return nil
+	}
+	c.savedSeq = save.seq
+	if err := s.persistPlayer(ctx, c.AccountID, save); err != nil {
+		s.logger.Warn().Err(err).Str("character_id", save.state.ID.String()).Msg("failed to persist player")
+		return err
+	}
+	return nil
+}
+
+// savePlayerAsync saves in the background and tells the player, if they are
+// still online, when the save failed.
+func (s *Service) savePlayerAsync(c *Client, save playerSave) {
+	if s.store == nil {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), positionUpdateTimeout)
+		defer cancel()
+		if err := s.savePlayer(ctx, c, save); err != nil {
+			s.sendIfOnline(c, map[string]any{"type": "error", "message": "failed to save your character; your last change may be lost"})
+		}
+	}()
+}
+
+// sendIfOnline delivers payload unless the client has already left; its
+// channel is only closed after it is dropped from s.clients.
+func (s *Service) sendIfOnline(c *Client, payload any) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if _, ok := s.clients[c]; ok {
+		nonBlockingSendJSON(c.Send, payload)
+	}
+}
+
+func (s *Service) persistPlayer(ctx context.Context, accountID uuid.UUID, save playerSave) error {
+	if s.store == nil {
+		return nil
+	}
+	state := save.state
+	progress := character.Progress{
+		Level:      state.Level,
+		Experience: state.Experience,
+		Gold:       state.Gold,
+		HP:         state.HP,
+		MaxHP:      state.MaxHP,
+		XPDebt:     state.XPDebt,
+	}
+	var errs []error
+	if err := s.store.UpdatePosition(ctx, accountID, state.ID, state.X, state.Y, state.ZoneID); err != nil {
+		errs = append(errs, fmt.Errorf("save position: %w", err))
+	}
+	if err := s.store.SaveCharacter(ctx, accountID, state.ID, progress, save.slots, equippedItems(state.Equipment, state.Wear), save.quests); err != nil {
+		errs = append(errs, fmt.Errorf("save character: %w", err))
+	}
+	return errors.Join(errs...)
+}
+
+func (s *Service) zoneOf(characterID uuid.UUID) *zone {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.playerZones[characterID]
+}
+
+func (s *Service) Join(ctx context.Context, c *Client, char character.Character) error {
+	var saved []character.InventorySlot
+	var equipped []character.EquippedItem
+	var quests []character.QuestProgress
+	if s.store != nil {
+		var err error
+		if saved, err = s.store.LoadInventory(ctx, c.AccountID, char.ID); err != nil {
+			return fmt.Errorf("load inventory: %w", err)
+		}
+		if equipped, err = s.store.LoadEquipment(ctx, c.AccountID, char.ID); err != nil {
+			return fmt.Errorf("load equipment: %w", err)
+		}
+		if quests, err = s.store.LoadQuests(ctx, c.AccountID, char.ID); err != nil {
+			return fmt.Errorf("load quests: %w", err)
+		}
+	}
+
+	z, ok := s.zones[char.ZoneID]
 	// Use saved position from DB, fallback to spawn if invalid
 	spawnX, spawnY := char.PosX, char.PosY
+	if !ok {
+		z = s.zones[s.defaultZoneID]
+		spawnX, spawnY = 0, 0
+	}
 	if spawnX <= 0 && spawnY <= 0 {
-		spawnX, spawnY = s.worldMap.Spawn.X, s.worldMap.Spawn.Y
+		spawnX, spawnY = z.worldMap.Spawn.X, z.worldMap.Spawn.Y
 	}
 	// Ensure spawn position is valid
-	if !s.isWalkable(spawnX, spawnY) {
+	if !z.isWalkable(spawnX, spawnY) {
 		spawnX = 1.5
 		spawnY = 1.5
 	}
 
 	c.CharacterID = char.ID
+	cls := s.classes.OrDefault(char.Class)
+	progress := char.Progress.WithDefaults()
 	player := domainworld.PlayerState{
 		ID:         char.ID,
 		Name:       char.Name,
 		X:          spawnX,
 		Y:          spawnY,
-		HP:         100,
-		MaxHP:      100,
-		Class:      char.Class,
-		Level:      1,
-		Experience: 0,
-		Gold:       100,
-		ZoneID:     s.zoneID,
+		HP:         progress.HP,
+		MaxHP:      progress.MaxHP,
+		Class:      cls.ID,
+		Level:      progress.Level,
+		Experience: progress.Experience,
+		Gold:       progress.Gold,
+		XPDebt:     progress.XPDebt,
+		ZoneID:     z.id,
+	}
+	player.Equipment, player.Wear = restoreEquipment(equipped, s.items)
+
+	z.mu.Lock()
+	pr := &playerRuntime{
+		State:     player,
+		Class:     cls,
+		Client:    c,
+		Inventory: newInventory(s.items, character.InventoryCapacity, saved),
+		Quests:    restoreQuestLog(quests, s.quests),
 	}
+	refreshStats(pr, s.items)
+	pr.State.Resource = pr.State.MaxResource
+	player = pr.State
+	observers := z.addPlayerLocked(pr)
+	welcome := z.welcomePayloadLocked("welcome", pr)
+	welcome["quests"] = s.questLogMessage(pr)["quests"]
+	welcome["class"] = cls
+	welcome["abilities"] = s.classAbilities(cls)
+	nonBlockingSendJSON(c.Send, welcome)
+	z.sendLocked(observers, map[string]any{"type": "player_joined", "player": player})
+	z.mu.Unlock()
 
 	s.mu.Lock()
-	s.players[char.ID] = &playerRuntime{State: player}
-	players := s.playersInZoneLocked(s.zoneID)
-	mobs := s.mobStatesLocked(s.zoneID)
-	npcs := append([]domainworld.NPC(nil), s.npcs...)
-	worldMap := s.worldMap
+	s.playerZones[char.ID] = z
 	s.mu.Unlock()
+	s.addOnline(partyMember{ID: char.ID, Name: char.Name, Client: c})
 
-	nonBlockingSendJSON(c.Send, map[string]any{
-		"type":       "welcome",
-		"selfId":     player.ID,
-		"character":  player,
-		"zone_id":    s.zoneID,
-		"world": map[string]any{
-			"zone_id": s.zoneID,
-			"map":     worldMap,
-			"players": players,
-			"mobs":    mobs,
-			"npcs":    npcs,
-		},
-	})
-
-	s.broadcastZone(char.ID, s.zoneID, map[string]any{"type": "player_joined", "player": player})
-	s.broadcastZone(uuid.Nil, s.zoneID, map[string]any{"type": "broadcast", "message": fmt.Sprintf("%s joined the world", player.Name)})
-}
-
-func (s *Service) Move(c *Client, dx, dy float64) {
-	if math.Abs(dx) < 1e-6 && math.Abs(dy) < 1e-6 {
+	z.broadcast(uuid.Nil, map[string]any{"type": "broadcast", "message": fmt.Sprintf("%s joined the world", player.Name)})
+	return nil
+}
+
+func (s *Service) Move(c *Client, dx, dy float64, seq uint64) {
+	dx, dy, ok := normalizeMove(dx, dy)
+	if !ok {
 		return
 	}
-	norm := math.Hypot(dx, dy)
-	if norm > 1 {
-		dx /= norm
-		dy /= norm
+	z := s.zoneOf(c.CharacterID)
+	if z == nil {
+		return
 	}
-	stepX := dx * playerMoveSpeed
-	stepY := dy * playerMoveSpeed
-
-	s.mu.Lock()
-	pr, ok := s.players[c.CharacterID]
+	z.mu.Lock()
+	pr, ok := z.players[c.CharacterID]
 	if !ok {
-		s.mu.Unlock()
+		z.mu.Unlock()
 		return
 	}
-
-	nextX := pr.State.X + stepX
-	nextY := pr.State.Y
-	if s.isWalkableWithRadius(nextX, nextY, playerCollisionRadius) {
-		pr.State.X = nextX
-	}
-	nextX = pr.State.X
-	nextY = pr.State.Y + stepY
-	if s.isWalkableWithRadius(nextX, nextY, playerCollisionRadius) {
-		pr.State.Y = nextY
+	if pr.State.Dead && !pr.State.Ghost {
+		z.mu.Unlock()
+		nonBlockingSendJSON(c.Send, map[string]any{"type": "error", "message": "you are dead"})
+		return
 	}
-	newX, newY := pr.State.X, pr.State.Y
-	zoneID := pr.State.ZoneID
-	s.mu.Unlock()
-
-	s.broadcastZone(uuid.Nil, zoneID, map[string]any{
-		"type":      "player_moved",
-		"player_id": c.CharacterID,
-		"x":         newX,
-		"y":         newY,
-	})
+	pr.queueMove(dx, dy, seq)
+	events := standUpLocked(pr)
+	z.mu.Unlock()
+	z.dispatch(events)
+}
 
-	// Persist position to DB (async, don't block)
-	if s.updater != nil {
-		go func() {
-			for attempt := 1; attempt <= positionUpdateRetries; attempt++ {
-				ctx, cancel := context.WithTimeout(context.Background(), positionUpdateTimeout)
-				err := s.updater.UpdatePosition(ctx, c.AccountID, c.CharacterID, newX, newY, zoneID)
-				cancel()
-				if err == nil {
-					return
-				}
-
-				s.logger.Warn().Err(err).Str("character_id", c.CharacterID.String()).Int("attempt", attempt).Msg("position save failed")
-				if attempt < positionUpdateRetries {
-					time.Sleep(positionRetryBackoff * time.Duration(attempt))
-				}
-			}
-		}()
+func (s *Service) transferPlayer(c *Client, from *zone, portal domainworld.Portal) bool {
+	to, ok := s.zones[portal.TargetZoneID]
+	if !ok || to == from {
+		return false
+	}
+	x, y := portal.TargetSpawn.X, portal.TargetSpawn.Y
+	if !to.isWalkableWithRadius(x, y, playerCollisionRadius) {
+		x, y = to.worldMap.Spawn.X, to.worldMap.Spawn.Y
 	}
-}
 
-func (s *Service) Attack(c *Client, targetID string) {
 	s.mu.Lock()
-	pr, ok := s.players[c.CharacterID]
-	if !ok {
+	if _, registered := s.clients[c]; !registered || s.playerZones[c.CharacterID] != from {
 		s.mu.Unlock()
-		return
+		return false
 	}
-	mob, ok := s.mobs[targetID]
-	if !ok || !mob.State.Alive {
+	from.mu.Lock()
+	pr, observers := from.removePlayerLocked(c.CharacterID)
+	if pr == nil {
+		from.mu.Unlock()
 		s.mu.Unlock()
-		nonBlockingSendJSON(c.Send, map[string]any{"type": "error", "message": "invalid mob target"})
-		return
-	}
-
-	d := distance(pr.State.X, pr.State.Y, mob.State.X, mob.State.Y)
-	if d > playerAttackRange {
-		s.mu.Unlock()
-		nonBlockingSendJSON(c.Send, map[string]any{"type": "error", "message": "target out of range"})
-		return
+		return false
 	}
-
-	dmg := basePlayerDamage + (pr.State.Level-1)*3
-	mob.State.HP -= dmg
-	zoneID := pr.State.ZoneID
+	from.sendLocked(observers, map[string]any{"type": "player_left", "player_id": c.CharacterID})
+	fromTick := from.tick
+	from.mu.Unlock()
+
+	pr.State.ZoneID = to.id
+	pr.State.X = x
+	pr.State.Y = y
+	to.mu.Lock()
+	pr.State.Effects = rebaseEffects(pr.State.Effects, fromTick, to.tick)
+	pr.LastCombatTick = rebaseTick(pr.LastCombatTick, fromTick, to.tick)
+	pr.NextPositionSave = 0
+	observers = to.addPlayerLocked(pr)
+	welcome := to.welcomePayloadLocked("zone_changed", pr)
+	welcome["from_zone_id"] = from.id
+	nonBlockingSendJSON(c.Send, welcome)
+	to.sendLocked(observers, map[string]any{"type": "player_joined", "player": pr.State})
+	player := pr.State
+	to.mu.Unlock()
+	s.playerZones[c.CharacterID] = to
 	s.mu.Unlock()
 
-	s.broadcastZone(uuid.Nil, zoneID, map[string]any{"type": "combat", "attacker": c.CharacterID.String(), "target": targetID, "damage": dmg})
-
-	s.mu.Lock()
-	mob, ok = s.mobs[targetID]
-	if ok && mob.State.HP <= 0 && mob.State.Alive {
-		mob.State.Alive = false
-		mob.RespawnCounter = mobRespawnTicks
-		mob.State.HP = 0
-		pr.State.Experience += 25
-		for pr.State.Experience >= pr.State.Level*100 {
-			pr.State.Experience -= pr.State.Level * 100
-			pr.State.Level++
-			pr.State.MaxHP += 20
-			pr.State.HP = pr.State.MaxHP
-		}
-	}
-	dead := ok && !mob.State.Alive && mob.RespawnCounter == mobRespawnTicks
-	playerSnapshot := pr.State
-	s.mu.Unlock()
-
-	if dead {
-		s.broadcastZone(uuid.Nil, zoneID, map[string]any{"type": "mob_died", "mob_id": targetID})
-		s.broadcastZone(uuid.Nil, zoneID, map[string]any{"type": "broadcast", "message": fmt.Sprintf("%s defeated %s", playerSnapshot.Name, targetID)})
-		nonBlockingSendJSON(c.Send, map[string]any{"type": "player_update", "player": playerSnapshot})
-	}
+	s.persistPositionAsync(c, player.X, player.Y, player.ZoneID)
+	return true
 }
 
-func (s *Service) tickWorld() {
-	s.mu.Lock()
-	s.tick++
-	events := s.stepMobsLocked()
-	mobs := s.mobStatesLocked(s.zoneID)
-	s.mu.Unlock()
-
-	for _, evt := range events {
-		s.broadcastZone(uuid.Nil, evt.ZoneID, evt.Payload)
+func (s *Service) persistPositionAsync(c *Client, x, y float64, zoneID string) {
+	// Persist position to DB (async, don't block)
+	if s.store == nil {
+		return
 	}
-	s.broadcastZone(uuid.Nil, s.zoneID, map[string]any{"type": "mob_update", "mobs": mobs})
-}
-
-func (s *Service) stepMobsLocked() []zoneEvent {
-	events := make([]zoneEvent, 0)
-	for _, mob := range s.mobs {
-		if !mob.State.Alive {
-			if mob.RespawnCounter > 0 {
-				mob.RespawnCounter--
-			}
-			if mob.RespawnCounter == 0 {
-				mob.State.Alive = true
-				mob.State.HP = mob.State.MaxHP
-				mob.State.X = mob.SpawnX
-				mob.State.Y = mob.SpawnY
-				events = append(events, zoneEvent{
-					ZoneID: mob.State.ZoneID,
-					Payload: map[string]any{
-						"type":    "broadcast",
-						"message": fmt.Sprintf("%s has respawned", mob.State.Name),
-					},
-				})
+	go func() {
+		for attempt := 1; attempt <= positionUpdateRetries; attempt++ {
+			ctx, cancel := context.WithTimeout(context.Background(), positionUpdateTimeout)
+			err := s.store.UpdatePosition(ctx, c.AccountID, c.CharacterID, x, y, zoneID)
+			cancel()
+			if err == nil {
+				return
 			}
-			continue
-		}
 
-		target := s.closestPlayerInRangeLocked(mob.State.ZoneID, mob.State.X, mob.State.Y, mobAggroRange)
-		if target != nil {
-			d := distance(target.State.X, target.State.Y, mob.State.X, mob.State.Y)
-			if d <= mobAttackRange {
-				if mob.AttackCooldown > 0 {
-					mob.AttackCooldown--
-				} else {
-					events = append(events, s.applyMobAttackLocked(mob, target)...)
-					mob.AttackCooldown = mobAttackCooldownTicks
-				}
-			} else {
-				s.moveMobTowardsLocked(mob, target.State.X, target.State.Y)
-				if mob.AttackCooldown > 0 {
-					mob.AttackCooldown--
-				}
+			s.logger.Warn().Err(err).Str("character_id", c.CharacterID.String()).Int("attempt", attempt).Msg("position save failed")
+			if attempt < positionUpdateRetries {
+				time.Sleep(positionRetryBackoff * time.Duration(attempt))
 			}
-			continue
 		}
-
-		if mob.AttackCooldown > 0 {
-			mob.AttackCooldown--
-		}
-		s.wanderMobLocked(mob)
-	}
-	return events
+	}()
 }
 
-func (s *Service) moveMobTowardsLocked(mob *mobRuntime, x, y float64) {
-	dx := x - mob.State.X
-	dy := y - mob.State.Y
-	n := math.Hypot(dx, dy)
-	if n < 1e-6 {
+func (s *Service) Attack(c *Client, targetID string) {
+	z := s.zoneOf(c.CharacterID)
+	if z == nil {
 		return
 	}
-	dx = dx / n * mobMoveSpeed
-	dy = dy / n * mobMoveSpeed
-	nx := mob.State.X + dx
-	ny := mob.State.Y + dy
-	if s.withinPatrol(mob, nx, ny) && s.isWalkableWithRadius(nx, ny, 0.2) {
-		mob.State.X = nx
-		mob.State.Y = ny
+	z.mu.Lock()
+	pr, ok := z.players[c.CharacterID]
+	if !ok {
+		z.mu.Unlock()
+		return
 	}
-}
-
-func (s *Service) wanderMobLocked(mob *mobRuntime) {
-	if mob.WanderTicksRemain <= 0 {
-		ang := s.rand.Float64() * 2 * math.Pi
-		mob.WanderDX = math.Cos(ang) * mobMoveSpeed * 0.7
-		mob.WanderDY = math.Sin(ang) * mobMoveSpeed * 0.7
-		mob.WanderTicksRemain = 5 + s.rand.Intn(mobWanderMaxTicks)
+	if pr.State.Dead {
+		z.mu.Unlock()
+		nonBlockingSendJSON(c.Send, map[string]any{"type": "error", "message": "you are dead"})
+		return
 	}
-	mob.WanderTicksRemain--
-	nx := mob.State.X + mob.WanderDX
-	ny := mob.State.Y + mob.WanderDY
-	if !s.withinPatrol(mob, nx, ny) || !s.isWalkableWithRadius(nx, ny, 0.2) {
-		mob.WanderTicksRemain = 0
+	mob, ok := z.mobs[targetID]
+	if !ok || !mob.State.Alive {
+		z.mu.Unlock()
+		nonBlockingSendJSON(c.Send, map[string]any{"type": "error", "message": "invalid mob target"})
 		return
 	}
-	mob.State.X = nx
-	mob.State.Y = ny
-}
-
-func (s *Service) applyMobAttackLocked(mob *mobRuntime, pr *playerRuntime) []zoneEvent {
-	events := []zoneEvent{{
-		ZoneID: pr.State.ZoneID,
-		Payload: map[string]any{
-			"type":     "combat",
-			"attacker": mob.State.ID,
-			"target":   pr.State.ID.String(),
-			"damage":   mob.State.Damage,
-		},
-	}}
-	pr.State.HP -= mob.State.Damage
-	if pr.State.HP > 0 {
-		return events
-	}
-	pr.State.HP = pr.State.MaxHP
-	pr.State.X = s.worldMap.Spawn.X
-	pr.State.Y = s.worldMap.Spawn.Y
-	events = append(events, zoneEvent{ZoneID: pr.State.ZoneID, Payload: map[string]any{"type": "player_died", "player_id": pr.State.ID}})
-	events = append(events, zoneEvent{ZoneID: pr.State.ZoneID, Payload: map[string]any{"type": "player_moved", "player_id": pr.State.ID, "x": pr.State.X, "y": pr.State.Y}})
-	return events
-}
-
-func (s *Service) withinPatrol(mob *mobRuntime, x, y float64) bool {
-	return distance(mob.SpawnX, mob.SpawnY, x, y) <= mob.State.PatrolRadius
-}
-
-func (s *Service) closestPlayerInRangeLocked(zoneID string, x, y, rng float64) *playerRuntime {
-	var best *playerRuntime
-	bestDist := math.MaxFloat64
-	for _, p := range s.players {
-		if p.State.ZoneID != zoneID || p.State.HP <= 0 {
-			continue
-		}
-		d := distance(x, y, p.State.X, p.State.Y)
-		if d <= rng && d < bestDist {
-			best = p
-			bestDist = d
-		}
+	if mob.State.AIState == domainworld.MobAIStateEvade {
+		z.mu.Unlock()
+		nonBlockingSendJSON(c.Send, map[string]any{"type": "error", "message": "target is evading"})
+		return
 	}
-	return best
-}
-
-func (s *Service) playersInZoneLocked(zoneID string) []domainworld.PlayerState {
-	players := make([]domainworld.PlayerState, 0)
-	for _, p := range s.players {
-		if p.State.ZoneID == zoneID {
-			players = append(players, p.State)
-		}
+	if pr.Mods.Stunned {
+		z.mu.Unlock()
+		nonBlockingSendJSON(c.Send, map[string]any{"type": "error", "message": "you are stunned"})
+		return
 	}
-	return players
-}
-
-func (s *Service) mobStatesLocked(zoneID string) []domainworld.MobState {
-	mobs := make([]domainworld.MobState, 0, len(s.mobs))
-	for _, m := range s.mobs {
-		if m.State.ZoneID == zoneID {
-			mobs = append(mobs, m.State)
-		}
+	if pr.Cast != nil {
+		z.mu.Unlock()
+		nonBlockingSendJSON(c.Send, map[string]any{"type": "error", "message": "already casting"})
+		return
 	}
-	return mobs
-}
-
-func (s *Service) isWalkable(x, y float64) bool {
-	return s.isWalkableWithRadius(x, y, 0)
-}
-
-func (s *Service) isWalkableWithRadius(x, y, radius float64) bool {
-	checks := [][2]float64{{x, y}, {x - radius, y}, {x + radius, y}, {x, y - radius}, {x, y + radius}}
-	for _, c := range checks {
-		t := s.tileAt(c[0], c[1])
-		if t == domainworld.TileWall || t == domainworld.TileWater {
-			return false
-		}
+	if z.tick < pr.GCDUntil {
+		z.mu.Unlock()
+		nonBlockingSendJSON(c.Send, map[string]any{"type": "error", "message": "global cooldown"})
+		return
 	}
-	return true
-}
 
-func (s *Service) tileAt(x, y float64) domainworld.TileType {
-	if x < 0 || y < 0 {
-		return domainworld.TileWall
+	d := distance(pr.State.X, pr.State.Y, mob.State.X, mob.State.Y)
+	if d > pr.Class.AttackRange {
+		z.mu.Unlock()
+		nonBlockingSendJSON(c.Send, map[string]any{"type": "error", "message": "target out of range"})
+		return
 	}
-	tx := int(math.Floor(x))
-	ty := int(math.Floor(y))
-	if tx < 0 || tx >= s.worldMap.Width || ty < 0 || ty >= s.worldMap.Height {
-		return domainworld.TileWall
+
+	// A basic attack shares the global cooldown with abilities, which also
+	// works as its swing timer.
+	pr.GCDUntil = z.tick + globalCooldownTicks
+	dmg := pr.State.Stats.AttackPower
+	events := standUpLocked(pr)
+	events = append(events, zoneEvent{X: mob.State.X, Y: mob.State.Y, Payload: map[string]any{"type": "combat", "attacker": c.CharacterID.String(), "target": targetID, "damage": dmg}})
+	killEvents, self := s.damageMobLocked(z, pr, mob, dmg)
+	events = append(events, killEvents...)
+	z.mu.Unlock()
+
+	z.dispatch(events)
+	for _, msg := range self {
+		nonBlockingSendJSON(c.Send, msg)
 	}
-	return s.worldMap.Tiles[ty][tx]
 }
 
-func (s *Service) broadcastZone(skipPlayerID uuid.UUID, zoneID string, payload any) {
-	b, err := json.Marshal(payload)
-	if err != nil {
-		s.logger.Error().Err(err).Msg("marshal ws payload failed")
-		return
+// damageMobLocked applies a player's damage to a mob. If that kills it, the
+// kill is announced and XP, loot and quest credit are paid out; the returned
+// events are for the zone and the messages for the attacker.
+func (s *Service) damageMobLocked(z *zone, pr *playerRuntime, mob *mobRuntime, dmg int) ([]zoneEvent, []map[string]any) {
+	mob.State.HP -= dmg
+	mob.engage(pr.State.ID, float64(dmg))
+	events := z.enterCombatLocked(pr)
+	if mob.State.HP > 0 || !mob.State.Alive {
+		return events, nil
 	}
-
-	s.mu.RLock()
-	clients := make([]*Client, 0, len(s.clients))
-	for c := range s.clients {
-		if c.CharacterID == uuid.Nil {
+	mobX, mobY := mob.State.X, mob.State.Y
+	mob.die()
+	events = append(events,
+		zoneEvent{X: mobX, Y: mobY, Payload: map[string]any{"type": "mob_died", "mob_id": mob.State.ID}},
+		zoneEvent{Global: true, Payload: map[string]any{"type": "broadcast", "message": fmt.Sprintf("%s defeated %s", pr.State.Name, mob.State.ID)}},
+		z.dropLootLocked(mob, pr),
+	)
+	var self []map[string]any
+	credited := s.killCreditLocked(z, pr, mobX, mobY)
+	for i, xp := range splitXP(mob.Template.XPReward, len(credited)) {
+		member := credited[i]
+		s.grantXPLocked(member, xp)
+		updates := s.recordObjectiveLocked(member, domainworld.QuestObjectiveKill, mob.Template.ID)
+		if member == pr {
+			self = updates
 			continue
 		}
-		if skipPlayerID != uuid.Nil && c.CharacterID == skipPlayerID {
-			continue
+		nonBlockingSendJSON(member.Client.Send, map[string]any{"type": "player_update", "player": member.State})
+		for _, u := range updates {
+			nonBlockingSendJSON(member.Client.Send, u)
 		}
-		pr, ok := s.players[c.CharacterID]
-		if !ok || pr.State.ZoneID != zoneID {
-			continue
-		}
-		clients = append(clients, c)
-	}
-	s.mu.RUnlock()
-
-	for _, c := range clients {
-		nonBlockingSend(c.Send, b)
 	}
+	return events, append([]map[string]any{{"type": "player_update", "player": pr.State}}, self...)
 }
 
-func (s *Service) WorldState() domainworld.WorldState {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	players := make([]domainworld.PlayerState, 0, len(s.players))
-	for _, p := range s.players {
-		players = append(players, p.State)
+// grantXPLocked adds experience and applies any level-ups, each of which
+// refreshes derived stats and restores full HP and resource. Half of the XP
+// goes to paying off any death debt first.
+func (s *Service) grantXPLocked(pr *playerRuntime, xp int) {
+	if pr.State.XPDebt > 0 {
+		repaid := min(pr.State.XPDebt, xp/2)
+		pr.State.XPDebt -= repaid
+		xp -= repaid
 	}
-	mobs := make([]domainworld.MobState, 0, len(s.mobs))
-	for _, m := range s.mobs {
-		mobs = append(mobs, m.State)
+	pr.State.Experience += xp
+	for pr.State.Experience >= pr.State.Level*100 {
+		pr.State.Experience -= pr.State.Level * 100
+		pr.State.Level++
+		refreshStats(pr, s.items)
+		pr.State.HP = pr.State.MaxHP
+		pr.State.Resource = pr.State.MaxResource
 	}
-	npcs := append([]domainworld.NPC(nil), s.npcs...)
+}
 
-	return domainworld.WorldState{
-		Tick:    s.tick,
-		ZoneID:  s.zoneID,
-		Map:     s.worldMap,
-		Players: players,
-		NPCs:    npcs,
-		Mobs:    mobs,
-	}
+func (s *Service) WorldState() domainworld.WorldState {
+	return s.zones[s.defaultZoneID].state()
 }
 
-func (s *Service) OnlinePlayers() []domainworld.PlayerState {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	players := make([]domainworld.PlayerState, 0, len(s.players))
-	for _, p := range s.players {
-		players = append(players, p.State)
+func (s *Service) ZoneState(zoneID string) (domainworld.WorldState, bool) {
+	z, ok := s.zones[zoneID]
+	if !ok {
+		return domainworld.WorldState{}, false
 	}
-	return players
+	return z.state(), true
 }
 
-func loadWorldMap(path string, zoneID string) (domainworld.TileMap, []domainworld.NPC, []domainworld.MobState, error) {
-	if path == "" {
-		return domainworld.TileMap{}, nil, nil, fmt.Errorf("empty world map path")
-	}
-	b, err := os.ReadFile(path)
-	if err != nil {
-		return domainworld.TileMap{}, nil, nil, fmt.Errorf("read world map: %w", err)
-	}
-	var data MapJSON
-	if err := json.Unmarshal(b, &data); err != nil {
-		return domainworld.TileMap{}, nil, nil, fmt.Errorf("parse world map json: %w", err)
-	}
-	if data.Width <= 0 || data.Height <= 0 {
-		return domainworld.TileMap{}, nil, nil, fmt.Errorf("invalid map dimensions")
-	}
-	if len(data.Rows) != data.Height {
-		return domainworld.TileMap{}, nil, nil, fmt.Errorf("rows count must equal height")
-	}
-	tiles := make([][]domainworld.TileType, data.Height)
-	for y := 0; y < data.Height; y++ {
-		if len(data.Rows[y]) != data.Width {
-			return domainworld.TileMap{}, nil, nil, fmt.Errorf("row %d width mismatch", y)
-		}
-		row := make([]domainworld.TileType, data.Width)
-		for x, r := range data.Rows[y] {
-			switch r {
-			case '.':
-				row[x] = domainworld.TileGrass
-			case '~':
-				row[x] = domainworld.TileWater
-			case '#':
-				row[x] = domainworld.TileWall
-			case '^':
-				row[x] = domainworld.TileForest
-			default:
-				return domainworld.TileMap{}, nil, nil, fmt.Errorf("unknown tile rune %q", string(r))
-			}
-		}
-		tiles[y] = row
-	}
+// Classes lists the playable classes by ID.
+func (s *Service) Classes() []character.Class {
+	return s.classes.List()
+}
 
-	npcs := make([]domainworld.NPC, 0, len(data.NPCs))
-	for _, npc := range data.NPCs {
-		if npc.ID == "" {
-			continue
-		}
-		interactions := make([]string, 0, len(npc.Interactions))
-		for _, interaction := range npc.Interactions {
-			interactions = append(interactions, strings.ToLower(interaction))
-		}
-		npcs = append(npcs, domainworld.NPC{
-			ID:           npc.ID,
-			Name:         npc.Name,
-			Role:         npc.Role,
-			Interactions: interactions,
-			Dialogue:     npc.Dialogue,
-			TradeItems:   npc.TradeItems,
-			QuestInfo:    npc.QuestInfo,
-			GoldPrice:    npc.GoldPrice,
-			X:            npc.X,
-			Y:            npc.Y,
-			ZoneID:       zoneID,
-		})
-	}
+// LookupClass finds a playable class by ID, ignoring case.
+func (s *Service) LookupClass(id string) (character.Class, bool) {
+	return s.classes.Lookup(id)
+}
 
-	mobs := make([]domainworld.MobState, 0, len(data.Mobs))
-	for _, m := range data.Mobs {
-		if m.ID == "" {
-			continue
-		}
-		hp := m.HP
-		if hp <= 0 {
-			hp = 60
-		}
-		dmg := m.Damage
-		if dmg <= 0 {
-			dmg = 8
-		}
-		patrol := m.PatrolRadius
-		if patrol <= 0 {
-			patrol = 5
-		}
-		mobs = append(mobs, domainworld.MobState{
-			ID:           m.ID,
-			Name:         m.Name,
-			X:            m.X,
-			Y:            m.Y,
-			HP:           hp,
-			MaxHP:        hp,
-			Damage:       dmg,
-			PatrolRadius: patrol,
-			ZoneID:       zoneID,
-			Alive:        true,
-		})
+func (s *Service) ZoneIDs() []string {
+	ids := make([]string, 0, len(s.zones))
+	for id := range s.zones {
+		ids = append(ids, id)
 	}
-
-	return domainworld.TileMap{Width: data.Width, Height: data.Height, Spawn: data.Spawn, Tiles: tiles}, npcs, mobs, nil
+	sort.Strings(ids)
+	return ids
 }
 
-func fallbackWorld(zoneID string) (domainworld.TileMap, []domainworld.NPC, []domainworld.MobState) {
-	width, height := 50, 50
-	tiles := make([][]domainworld.TileType, height)
-	for y := 0; y < height; y++ {
-		row := make([]domainworld.TileType, width)
-		for x := 0; x < width; x++ {
-			if x == 0 || y == 0 || x == width-1 || y == height-1 {
-				row[x] = domainworld.TileWall
-				continue
-			}
-			row[x] = domainworld.TileGrass
-		}
-		tiles[y] = row
+func (s *Service) OnlinePlayers() []domainworld.PlayerState {
+	players := make([]domainworld.PlayerState, 0)
+	for _, id := range s.ZoneIDs() {
+		z := s.zones[id]
+		z.mu.RLock()
+		players = append(players, z.playerStatesLocked()...)
+		z.mu.RUnlock()
 	}
-	return domainworld.TileMap{Width: width, Height: height, Spawn: domainworld.SpawnPoint{X: 2.5, Y: 2.5}, Tiles: tiles}, []domainworld.NPC{{ID: "npc-merchant-1", Name: "Rurik", Role: "merchant", Interactions: []string{"talk", "trade", "heal"}, Dialogue: "Welcome, traveler! What can I offer you today?", TradeItems: []string{"Health Potion", "Iron Sword", "Leather Armor"}, GoldPrice: 50, X: 5, Y: 5, ZoneID: zoneID}}, []domainworld.MobState{{ID: "mob-slime-1", Name: "Green Slime", X: 14, Y: 12, HP: 60, MaxHP: 60, Damage: 8, PatrolRadius: 6, ZoneID: zoneID, Alive: true}}
+	return players
 }
 
 func distance(ax, ay, bx, by float64) float64 {
 	return math.Hypot(ax-bx, ay-by)
 }
 
-func nonBlockingSend(ch chan []byte, msg []byte) {
+func nonBlockingSend(ch chan []byte, msg []byte) bool {
 	select {
 	case ch <- msg:
+		return true
 	default:
+		return false
 	}
 }
 
-func (s *Service) findNPC(npcID string) *domainworld.NPC {
-	for i := range s.npcs {
-		if s.npcs[i].ID == npcID {
-			return &s.npcs[i]
-		}
-	}
-	return nil
-}
-
 func contains[T comparable](slice []T, elem T) bool {
 	for _, v := range slice {
 		if v == elem {
@@ -790,54 +877,126 @@ func contains[T comparable](slice []T, elem T) bool {
 	return false
 }
 
-func (s *Service) Interact(c *Client, npcID, action string) {
-	s.mu.RLock()
-	npc := s.findNPC(npcID)
-	pr, exists := s.players[c.CharacterID]
-	if !exists || npc == nil {
-		s.mu.RUnlock()
+// InteractArgs carries the optional parameters of an NPC interaction, such as
+// the item and quantity of a trade.
+type InteractArgs struct {
+	ItemID   string
+	Slot     int
+	Quantity int
+	QuestID  string
+}
+
+func (s *Service) Interact(c *Client, npcID, action string, args InteractArgs) {
+	z := s.zoneOf(c.CharacterID)
+	if z == nil {
 		nonBlockingSendJSON(c.Send, map[string]any{"type": "error", "message": "NPC not found"})
 		return
 	}
 	action = strings.ToLower(action)
-	if !contains(npc.Interactions, action) {
-		s.mu.RUnlock()
+	required := action
+	switch action {
+	case npcActionBuy, npcActionSell:
+		required = string(domainworld.InteractionTypeTrade)
+	case npcActionAccept, npcActionAbandon, npcActionTurnIn:
+		required = string(domainworld.InteractionTypeQuest)
+	}
+
+	z.mu.Lock()
+	npc := z.findNPC(npcID)
+	pr, exists := z.players[c.CharacterID]
+	if !exists || npc == nil {
+		z.mu.Unlock()
+		nonBlockingSendJSON(c.Send, map[string]any{"type": "error", "message": "NPC not found"})
+		return
+	}
+	if pr.State.Dead {
+		z.mu.Unlock()
+		nonBlockingSendJSON(c.Send, map[string]any{"type": "error", "message": "you are dead"})
+		return
+	}
+	if !contains(npc.Interactions, required) {
+		z.mu.Unlock()
 		nonBlockingSendJSON(c.Send, map[string]any{"type": "error", "message": fmt.Sprintf("Action %s not available for this NPC", action)})
 		return
 	}
-	s.mu.RUnlock()
 
 	result := map[string]any{}
-	healUpdated := false
+	var err error
+	var questUpdates []map[string]any
+	changed := false
+	questsChanged := false
 	switch action {
 	case string(domainworld.InteractionTypeTalk):
 		result["text"] = npc.Dialogue
+		if node, ok := s.startDialogueLocked(pr, npc); ok {
+			result["text"] = node["text"]
+			result["node"] = node
+		}
+		if inNPCRange(pr, npc) {
+			questUpdates = s.recordObjectiveLocked(pr, domainworld.QuestObjectiveTalk, npc.ID)
+		}
 	case string(domainworld.InteractionTypeTrade):
-		result["items"] = npc.TradeItems
+		result["stock"] = npc.Stock
+		result["sell_prices"] = s.sellPrices(pr.Inventory)
 	case string(domainworld.InteractionTypeQuest):
-		result["info"] = npc.QuestInfo
+		result["quests"] = s.npcQuestsLocked(pr, npc.ID)
 	case string(domainworld.InteractionTypeHeal):
 		price := npc.GoldPrice
 		if price <= 0 {
 			price = 50
 		}
-		s.mu.Lock()
-		pr, exists = s.players[c.CharacterID]
-		if !exists || pr.State.Gold < price {
-			s.mu.Unlock()
-			nonBlockingSendJSON(c.Send, map[string]any{"type": "error", "message": fmt.Sprintf("Not enough gold (need %d)", price)})
-			return
+		if pr.State.Gold < price {
+			err = fmt.Errorf("not enough gold (need %d)", price)
+			break
 		}
-		oldHP := pr.State.HP
-		pr.State.HP = pr.State.MaxHP
-		pr.State.Gold -= price
-		s.mu.Unlock()
 		result["success"] = true
 		result["hp_restored"] = true
 		result["gold_spent"] = price
-		result["old_hp"] = oldHP
+		result["old_hp"] = pr.State.HP
 		result["new_hp"] = pr.State.MaxHP
-		healUpdated = true
+		pr.State.HP = pr.State.MaxHP
+		pr.State.Gold -= price
+		changed = true
+	case string(domainworld.InteractionTypeRepair):
+		result, err = s.repairLocked(pr)
+		changed = err == nil
+	case npcActionBuy:
+		result, err = s.buyLocked(pr, npc, args.ItemID, args.Quantity)
+		changed = err == nil
+	case npcActionSell:
+		result, err = s.sellLocked(pr, npc, args.Slot, args.Quantity)
+		changed = err == nil
+	case npcActionAccept, npcActionAbandon, npcActionTurnIn:
+		if !inNPCRange(pr, npc) {
+			err = fmt.Errorf("too far from %s", npc.Name)
+			break
+		}
+		switch action {
+		case npcActionAccept:
+			result, err = s.acceptQuestLocked(pr, npc, args.QuestID)
+		case npcActionAbandon:
+			result, err = s.abandonQuestLocked(pr, args.QuestID)
+		default:
+			result, err = s.turnInQuestLocked(pr, npc, args.QuestID)
+			changed = err == nil
+		}
+		questsChanged = err == nil
+	}
+	questsChanged = questsChanged || len(questUpdates) > 0
+	var save playerSave
+	var player domainworld.PlayerState
+	var inv, questLog map[string]any
+	if changed || questsChanged {
+		save = snapshotPlayerLocked(pr)
+		player = pr.State
+		inv = inventoryMessage(pr)
+		questLog = s.questLogMessage(pr)
+	}
+	z.mu.Unlock()
+
+	if err != nil {
+		nonBlockingSendJSON(c.Send, map[string]any{"type": "error", "message": err.Error()})
+		return
 	}
 	resp := map[string]any{
 		"type":   "npc_response",
@@ -846,18 +1005,27 @@ func (s *Service) Interact(c *Client, npcID, action string) {
 		"result": result,
 	}
 	nonBlockingSendJSON(c.Send, resp)
-	if healUpdated {
-		s.mu.RLock()
-		pr, _ = s.players[c.CharacterID]
-		s.mu.RUnlock()
-		nonBlockingSendJSON(c.Send, map[string]any{"type": "player_update", "player": pr.State})
+	if changed {
+		nonBlockingSendJSON(c.Send, map[string]any{"type": "player_update", "player": player})
+		if action != string(domainworld.InteractionTypeHeal) {
+			nonBlockingSendJSON(c.Send, inv)
+		}
+	}
+	for _, u := range questUpdates {
+		nonBlockingSendJSON(c.Send, u)
+	}
+	if questsChanged {
+		nonBlockingSendJSON(c.Send, questLog)
+	}
+	if changed || questsChanged {
+		s.savePlayerAsync(c, save)
 	}
 }
 
-func nonBlockingSendJSON(ch chan []byte, payload any) {
+func nonBlockingSendJSON(ch chan []byte, payload any) bool {
 	b, err := json.Marshal(payload)
 	if err != nil {
-		return
+		return false
 	}
-	nonBlockingSend(ch, b)
+	return nonBlockingSend(ch, b)
 }
@@ -1,20 +1,30 @@
 package world
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/rs/zerolog"
 
 	"mmorp-server/internal/domain/character"
+	domainworld "mmorp-server/internal/domain/world"
 )
 
 func TestJoinMoveAndCollision(t *testing.T) {
-	svc := NewService(zerolog.Nop(), nil, nil, "starter-zone", 10, "../../../data/maps/starter-zone.json")
+	svc := NewService(zerolog.Nop(), nil, nil, "starter-zone", 10, "../../../data")
 	client := svc.RegisterClient(nil, uuid.New())
 	charID := uuid.New()
-	svc.Join(client, character.Character{ID: charID, Name: "Aria", Class: "mage", ZoneID: "starter-zone"})
+	svc.Join(context.Background(), client, character.Character{ID: charID, Name: "Aria", Class: "mage", ZoneID: "starter-zone"})
 
 	welcome := <-client.Send
 	var payload map[string]any
@@ -26,14 +36,14 @@ func TestJoinMoveAndCollision(t *testing.T) {
 	}
 
 	before := svc.WorldState().Players[0]
-	svc.Move(client, -1, 0)
+	moveAndTick(svc, client, -1, 0)
 	after := svc.WorldState().Players[0]
 	if after.X >= before.X {
 		t.Fatalf("expected x to reduce after move; before=%v after=%v", before.X, after.X)
 	}
 
 	for i := 0; i < 20; i++ {
-		svc.Move(client, -1, 0)
+		moveAndTick(svc, client, -1, 0)
 	}
 	afterWall := svc.WorldState().Players[0]
 	if afterWall.X < 1 {
@@ -41,23 +51,79 @@ func TestJoinMoveAndCollision(t *testing.T) {
 	}
 }
 
+func moveAndTick(svc *Service, c *Client, dx, dy float64) {
+	svc.Move(c, dx, dy, 0)
+	if z := svc.zoneOf(c.CharacterID); z != nil {
+		svc.tickZone(z)
+	}
+}
+
+// waitGlobalCooldown ticks the zone until the next attack or ability is
+// ready.
+func waitGlobalCooldown(svc *Service, z *zone) {
+	for i := 0; i < globalCooldownTicks; i++ {
+		svc.tickZone(z)
+	}
+}
+
+func TestMovementIsLimitedToOneStepPerTick(t *testing.T) {
+	svc := NewService(zerolog.Nop(), nil, nil, "starter-zone", 10, "../../../data")
+	client := svc.RegisterClient(nil, uuid.New())
+	svc.Join(context.Background(), client, character.Character{ID: uuid.New(), Name: "Aria", ZoneID: "starter-zone", PosX: 20.5, PosY: 3.5})
+	<-client.Send
+
+	before := svc.WorldState().Players[0]
+	for i := 0; i < 100; i++ {
+		svc.Move(client, 1, 0, 0)
+	}
+	if queued := svc.WorldState().Players[0]; queued.X != before.X {
+		t.Fatalf("expected movement to wait for the tick, moved from %v to %v", before.X, queued.X)
+	}
+	svc.Move(client, 0, 1, 0)
+	svc.tickZone(svc.zones["starter-zone"])
+
+	after := svc.WorldState().Players[0]
+	if after.X != before.X || math.Abs(after.Y-(before.Y+playerMoveSpeed)) > 1e-9 {
+		t.Fatalf("expected a single step along the latest input, got (%v,%v) from (%v,%v)", after.X, after.Y, before.X, before.Y)
+	}
+}
+
 func TestAttackAndMobRespawn(t *testing.T) {
-	svc := NewService(zerolog.Nop(), nil, nil, "starter-zone", 10, "../../../data/maps/starter-zone.json")
+	svc := NewService(zerolog.Nop(), nil, nil, "starter-zone", 10, "../../../data")
 	client := svc.RegisterClient(nil, uuid.New())
 	charID := uuid.New()
-	svc.Join(client, character.Character{ID: charID, Name: "Aria", Class: "warrior", ZoneID: "starter-zone"})
+	svc.Join(context.Background(), client, character.Character{ID: charID, Name: "Aria", Class: "warrior", ZoneID: "starter-zone"})
 	<-client.Send
 
 	// Move close to map mob at (16,16).
 	for i := 0; i < 40; i++ {
-		svc.Move(client, 1, 0)
+		moveAndTick(svc, client, 1, 0)
+	}
+	for i := 0; i < 40; i++ {
+		moveAndTick(svc, client, 0, 1)
 	}
+	// Movement is tick-driven now, so give the slime time to close in.
+	z := svc.zones["starter-zone"]
 	for i := 0; i < 40; i++ {
-		svc.Move(client, 0, 1)
+		z.mu.RLock()
+		pr, mob := z.players[charID], z.mobs["mob-slime-1"]
+		inRange := distance(pr.State.X, pr.State.Y, mob.State.X, mob.State.Y) <= pr.Class.AttackRange
+		z.mu.RUnlock()
+		if inRange {
+			break
+		}
+		svc.tickZone(z)
 	}
 
+	drainTypes(client)
 	svc.Attack(client, "mob-slime-1")
 	svc.Attack(client, "mob-slime-1")
+	if types := drainTypes(client); !contains(types, "error") {
+		t.Fatalf("expected a second swing inside the global cooldown to fail, got %v", types)
+	}
+	waitGlobalCooldown(svc, z)
+	svc.Attack(client, "mob-slime-1")
+	waitGlobalCooldown(svc, z)
 	svc.Attack(client, "mob-slime-1")
 
 	state := svc.WorldState()
@@ -71,8 +137,11 @@ func TestAttackAndMobRespawn(t *testing.T) {
 		t.Fatalf("expected mob-slime-1 to be dead after attacks")
 	}
 
-	for i := 0; i < mobRespawnTicks; i++ {
-		svc.tickWorld()
+	z.mu.RLock()
+	respawnTicks := z.mobs["mob-slime-1"].Template.RespawnTicks
+	z.mu.RUnlock()
+	for i := 0; i < respawnTicks; i++ {
+		svc.tickZone(svc.zones["starter-zone"])
 	}
 
 	state = svc.WorldState()
@@ -82,3 +151,1890 @@ func TestAttackAndMobRespawn(t *testing.T) {
 		}
 	}
 }
+
+type fakeCharacterStore struct {
+	mu        sync.Mutex
+	zones     []string
+	progress  map[uuid.UUID]character.Progress
+	inventory map[uuid.UUID][]character.InventorySlot
+	equipment map[uuid.UUID][]character.EquippedItem
+	quests    map[uuid.UUID][]character.QuestProgress
+	failSaves bool
+	// failQuests fails writing the quest log, which must roll back the rest
+	// of the save like the real transaction does.
+	failQuests bool
+}
+
+func newFakeCharacterStore() *fakeCharacterStore {
+	return &fakeCharacterStore{progress: make(map[uuid.UUID]character.Progress), inventory: make(map[uuid.UUID][]character.InventorySlot), equipment: make(map[uuid.UUID][]character.EquippedItem), quests: make(map[uuid.UUID][]character.QuestProgress)}
+}
+
+func (f *fakeCharacterStore) UpdatePosition(_ context.Context, _, _ uuid.UUID, _, _ float64, zoneID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.zones = append(f.zones, zoneID)
+	return nil
+}
+
+func (f *fakeCharacterStore) savedZone(zoneID string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return contains(f.zones, zoneID)
+}
+
+func (f *fakeCharacterStore) SaveCharacter(_ context.Context, _, characterID uuid.UUID, progress character.Progress, slots []character.InventorySlot, equipped []character.EquippedItem, quests []character.QuestProgress) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.failSaves {
+		return errors.New("database unavailable")
+	}
+	if f.failQuests && len(quests) > 0 {
+		return errors.New("insert quest: database unavailable")
+	}
+	f.progress[characterID] = progress
+	f.inventory[characterID] = slots
+	f.equipment[characterID] = equipped
+	f.quests[characterID] = quests
+	return nil
+}
+
+func (f *fakeCharacterStore) LoadInventory(_ context.Context, _, characterID uuid.UUID) ([]character.InventorySlot, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.inventory[characterID], nil
+}
+
+func (f *fakeCharacterStore) LoadEquipment(_ context.Context, _, characterID uuid.UUID) ([]character.EquippedItem, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.equipment[characterID], nil
+}
+
+func (f *fakeCharacterStore) LoadQuests(_ context.Context, _, characterID uuid.UUID) ([]character.QuestProgress, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.quests[characterID], nil
+}
+
+func TestJoinRestoresAndUnregisterPersistsProgress(t *testing.T) {
+	store := newFakeCharacterStore()
+	svc := NewService(zerolog.Nop(), nil, store, "starter-zone", 10, "../../../data")
+	client := svc.RegisterClient(nil, uuid.New())
+	charID := uuid.New()
+	saved := character.Progress{Level: 3, Experience: 40, Gold: 250, HP: 70, MaxHP: 140}
+	svc.Join(context.Background(), client, character.Character{ID: charID, Name: "Aria", Class: "mage", ZoneID: "starter-zone", Progress: saved})
+	<-client.Send
+
+	p := svc.WorldState().Players[0]
+	if p.Level != 3 || p.Experience != 40 || p.Gold != 250 || p.HP != 70 || p.MaxHP != 140 {
+		t.Fatalf("expected saved progression to be restored, got %+v", p)
+	}
+
+	z := svc.zones["starter-zone"]
+	z.mu.Lock()
+	z.players[charID].State.Gold = 275
+	z.mu.Unlock()
+
+	svc.UnregisterClient(context.Background(), client)
+	store.mu.Lock()
+	got, ok := store.progress[charID]
+	store.mu.Unlock()
+	if !ok {
+		t.Fatalf("expected progress to be persisted on unregister")
+	}
+	want := character.Progress{Level: 3, Experience: 40, Gold: 275, HP: 70, MaxHP: 140}
+	if got != want {
+		t.Fatalf("persisted progress mismatch: got %+v want %+v", got, want)
+	}
+}
+
+func writeTestMap(t *testing.T, dir, zoneID string, extra map[string]any) {
+	t.Helper()
+	rows := make([]string, 10)
+	for y := range rows {
+		if y == 0 || y == len(rows)-1 {
+			rows[y] = "##########"
+			continue
+		}
+		rows[y] = "#........#"
+	}
+	m := map[string]any{"width": 10, "height": 10, "spawn": map[string]any{"x": 2.5, "y": 2.5}, "rows": rows}
+	for k, v := range extra {
+		m[k] = v
+	}
+	b, err := json.Marshal(m)
+	if err != nil {
+		t.Fatalf("marshal map: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "maps"), 0o755); err != nil {
+		t.Fatalf("mkdir maps: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "maps", zoneID+".json"), b, 0o644); err != nil {
+		t.Fatalf("write map: %v", err)
+	}
+}
+
+func writeTestMobTemplates(t *testing.T, dir string, templates ...map[string]any) {
+	t.Helper()
+	b, err := json.Marshal(map[string]any{"mobs": templates})
+	if err != nil {
+		t.Fatalf("marshal mob templates: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "mobs.json"), b, 0o644); err != nil {
+		t.Fatalf("write mob templates: %v", err)
+	}
+}
+
+func TestJoinRoutesToCharacterZone(t *testing.T) {
+	dir := t.TempDir()
+	writeTestMap(t, dir, "town", nil)
+	writeTestMap(t, dir, "caves", map[string]any{
+		"spawn_groups": []map[string]any{{"id": "mob-bat", "template": "bat", "x": 7, "y": 7}},
+	})
+	writeTestMobTemplates(t, dir, map[string]any{"id": "bat", "name": "Bat", "hp": 30, "damage": 2})
+	svc := NewService(zerolog.Nop(), nil, nil, "town", 10, dir)
+	if got := svc.ZoneIDs(); len(got) != 2 || got[0] != "caves" || got[1] != "town" {
+		t.Fatalf("expected zones [caves town], got %v", got)
+	}
+
+	client := svc.RegisterClient(nil, uuid.New())
+	charID := uuid.New()
+	svc.Join(context.Background(), client, character.Character{ID: charID, Name: "Aria", ZoneID: "caves", PosX: 3.5, PosY: 4.5})
+	<-client.Send
+
+	caves, _ := svc.ZoneState("caves")
+	if len(caves.Players) != 1 || caves.Players[0].ZoneID != "caves" || caves.Players[0].X != 3.5 {
+		t.Fatalf("expected player in caves at saved position, got %+v", caves.Players)
+	}
+	if town := svc.WorldState(); len(town.Players) != 0 {
+		t.Fatalf("expected no players in town, got %d", len(town.Players))
+	}
+	if len(caves.Mobs) != 1 || caves.Mobs[0].ID != "mob-bat-1" {
+		t.Fatalf("expected caves mobs to be isolated, got %+v", caves.Mobs)
+	}
+
+	svc.tickZone(svc.zones["caves"])
+	caves, _ = svc.ZoneState("caves")
+	town := svc.WorldState()
+	if caves.Tick != 1 || town.Tick != 0 {
+		t.Fatalf("expected independent ticks, got caves=%d town=%d", caves.Tick, town.Tick)
+	}
+
+	other := svc.RegisterClient(nil, uuid.New())
+	svc.Join(context.Background(), other, character.Character{ID: uuid.New(), Name: "Bryn", ZoneID: "unknown-zone", PosX: 8, PosY: 8})
+	<-other.Send
+	town = svc.WorldState()
+	if len(town.Players) != 1 || town.Players[0].X != 2.5 || town.Players[0].Y != 2.5 {
+		t.Fatalf("expected unknown zone to fall back to default spawn, got %+v", town.Players)
+	}
+}
+
+func TestPortalTransfersPlayerBetweenZones(t *testing.T) {
+	dir := t.TempDir()
+	writeTestMap(t, dir, "town", map[string]any{
+		"portals": []map[string]any{{"id": "to-caves", "x": 6, "y": 2, "width": 1, "height": 1, "target_zone": "caves", "target_spawn": map[string]any{"x": 4.5, "y": 6.5}}},
+	})
+	writeTestMap(t, dir, "caves", nil)
+	store := newFakeCharacterStore()
+	svc := NewService(zerolog.Nop(), nil, store, "town", 10, dir)
+
+	watcher := svc.RegisterClient(nil, uuid.New())
+	svc.Join(context.Background(), watcher, character.Character{ID: uuid.New(), Name: "Bryn", ZoneID: "caves", PosX: 7.5, PosY: 7.5})
+	<-watcher.Send
+
+	client := svc.RegisterClient(nil, uuid.New())
+	charID := uuid.New()
+	svc.Join(context.Background(), client, character.Character{ID: charID, Name: "Aria", ZoneID: "town", PosX: 5.5, PosY: 2.5})
+	<-client.Send
+
+	moveAndTick(svc, client, 1, 0)
+	moveAndTick(svc, client, 1, 0)
+
+	if town := svc.WorldState(); len(town.Players) != 0 {
+		t.Fatalf("expected player to leave town, got %+v", town.Players)
+	}
+	caves, _ := svc.ZoneState("caves")
+	var arrived bool
+	for _, p := range caves.Players {
+		if p.ID == charID {
+			arrived = p.ZoneID == "caves" && p.X == 4.5 && p.Y == 6.5
+		}
+	}
+	if !arrived {
+		t.Fatalf("expected player at caves target spawn, got %+v", caves.Players)
+	}
+
+	var changed map[string]any
+	for len(client.Send) > 0 {
+		var msg map[string]any
+		if err := json.Unmarshal(<-client.Send, &msg); err != nil {
+			t.Fatalf("unmarshal: %v", err)
+		}
+		if msg["type"] == "zone_changed" {
+			changed = msg
+		}
+	}
+	if changed == nil || changed["zone_id"] != "caves" || changed["from_zone_id"] != "town" {
+		t.Fatalf("expected zone_changed snapshot, got %v", changed)
+	}
+
+	var joined bool
+	for len(watcher.Send) > 0 {
+		var msg map[string]any
+		if err := json.Unmarshal(<-watcher.Send, &msg); err != nil {
+			t.Fatalf("unmarshal: %v", err)
+		}
+		joined = joined || msg["type"] == "player_joined"
+	}
+	if !joined {
+		t.Fatalf("expected caves players to see player_joined")
+	}
+
+	svc.UnregisterClient(context.Background(), client)
+	if !store.savedZone("caves") {
+		t.Fatalf("expected caves to be persisted as the character zone")
+	}
+}
+
+func drainTypes(c *Client) []string {
+	types := make([]string, 0)
+	for len(c.Send) > 0 {
+		var msg map[string]any
+		if err := json.Unmarshal(<-c.Send, &msg); err == nil {
+			types = append(types, msg["type"].(string))
+		}
+	}
+	return types
+}
+
+func TestInterestLimitsUpdatesToViewRadius(t *testing.T) {
+	svc := NewService(zerolog.Nop(), nil, nil, "starter-zone", 10, "../../../data")
+	z := svc.zones["starter-zone"]
+
+	near := svc.RegisterClient(nil, uuid.New())
+	svc.Join(context.Background(), near, character.Character{ID: uuid.New(), Name: "Aria", ZoneID: "starter-zone", PosX: 3.5, PosY: 3.5})
+	far := svc.RegisterClient(nil, uuid.New())
+	svc.Join(context.Background(), far, character.Character{ID: uuid.New(), Name: "Bryn", ZoneID: "starter-zone", PosX: 45.5, PosY: 45.5})
+	walker := svc.RegisterClient(nil, uuid.New())
+	walkerID := uuid.New()
+	svc.Join(context.Background(), walker, character.Character{ID: walkerID, Name: "Cato", ZoneID: "starter-zone", PosX: 4.5, PosY: 3.5})
+	drainTypes(near)
+	drainTypes(far)
+	drainTypes(walker)
+
+	moveAndTick(svc, walker, 1, 0)
+	if got := drainTypes(near); !contains(got, "player_moved") {
+		t.Fatalf("expected nearby player to see movement, got %v", got)
+	}
+	if got := drainTypes(far); contains(got, "player_moved") {
+		t.Fatalf("expected distant player not to see movement, got %v", got)
+	}
+
+	z.mu.Lock()
+	pr := z.players[walkerID]
+	pr.State.X, pr.State.Y = 44.5, 44.5
+	z.playerGrid.Upsert(walkerID, pr.State.X, pr.State.Y)
+	z.mu.Unlock()
+	svc.tickZone(z)
+
+	if got := drainTypes(far); !contains(got, "entity_entered") {
+		t.Fatalf("expected distant player to get entity_entered, got %v", got)
+	}
+	if got := drainTypes(near); !contains(got, "entity_left") {
+		t.Fatalf("expected original neighbour to get entity_left, got %v", got)
+	}
+}
+
+func TestMobSnapshotSendsKeyframeThenDeltas(t *testing.T) {
+	svc := NewService(zerolog.Nop(), nil, nil, "starter-zone", 10, "../../../data")
+	z := svc.zones["starter-zone"]
+	client := svc.RegisterClient(nil, uuid.New())
+	charID := uuid.New()
+	svc.Join(context.Background(), client, character.Character{ID: charID, Name: "Aria", ZoneID: "starter-zone", PosX: 16.5, PosY: 14.5})
+	<-client.Send
+
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	pr := z.players[charID]
+	first := z.mobSnapshotLocked(pr)
+	if first["type"] != "mob_update" || first["keyframe"] != true {
+		t.Fatalf("expected initial keyframe, got %v", first)
+	}
+	if again := z.mobSnapshotLocked(pr); again != nil {
+		t.Fatalf("expected no snapshot when nothing changed, got %v", again)
+	}
+
+	z.mobs["mob-slime-1"].State.HP -= 10
+	delta := z.mobSnapshotLocked(pr)
+	if delta == nil || delta["type"] != "mob_delta" {
+		t.Fatalf("expected mob_delta, got %v", delta)
+	}
+	mobs := delta["mobs"].([]mobDelta)
+	if len(mobs) != 1 || mobs[0].ID != "mob-slime-1" || mobs[0].HP == nil || *mobs[0].HP != 50 || mobs[0].X != nil || mobs[0].Alive != nil {
+		t.Fatalf("expected only hp change for mob-slime-1, got %+v", mobs)
+	}
+
+	z.tick += snapshotKeyframeTicks
+	if keyframe := z.mobSnapshotLocked(pr); keyframe["keyframe"] != true {
+		t.Fatalf("expected periodic keyframe, got %v", keyframe)
+	}
+}
+
+func TestMoveAcknowledgesInputSequence(t *testing.T) {
+	svc := NewService(zerolog.Nop(), nil, nil, "starter-zone", 10, "../../../data")
+	z := svc.zones["starter-zone"]
+	watcher := svc.RegisterClient(nil, uuid.New())
+	svc.Join(context.Background(), watcher, character.Character{ID: uuid.New(), Name: "Bryn", ZoneID: "starter-zone", PosX: 22.5, PosY: 3.5})
+	client := svc.RegisterClient(nil, uuid.New())
+	svc.Join(context.Background(), client, character.Character{ID: uuid.New(), Name: "Aria", ZoneID: "starter-zone", PosX: 20.5, PosY: 3.5})
+	drainTypes(watcher)
+	drainTypes(client)
+
+	svc.Move(client, 1, 0, 5)
+	svc.Move(client, 0, 1, 7)
+	svc.Move(client, -1, 0, 6)
+	svc.tickZone(z)
+
+	if got := client.LastProcessedSeq(); got != 7 {
+		t.Fatalf("expected last processed seq 7, got %d", got)
+	}
+	var own map[string]any
+	for len(client.Send) > 0 {
+		var msg map[string]any
+		_ = json.Unmarshal(<-client.Send, &msg)
+		if msg["type"] == "player_moved" {
+			own = msg
+		}
+	}
+	if own == nil || own["seq"] != float64(7) || own["tick"] != float64(z.tick) || own["y"] != 3.5+playerMoveSpeed {
+		t.Fatalf("expected owner ack for seq 7 at tick %d, got %v", z.tick, own)
+	}
+	for len(watcher.Send) > 0 {
+		var msg map[string]any
+		_ = json.Unmarshal(<-watcher.Send, &msg)
+		if msg["type"] == "player_moved" {
+			if _, leaked := msg["seq"]; leaked {
+				t.Fatalf("expected other players not to receive input seq, got %v", msg)
+			}
+			return
+		}
+	}
+	t.Fatalf("expected watcher to see player_moved")
+}
+
+func TestMoveAckSkipsPlayersWhoLeft(t *testing.T) {
+	svc := NewService(zerolog.Nop(), nil, nil, "starter-zone", 10, "../../../data")
+	z := svc.zones["starter-zone"]
+	client := svc.RegisterClient(nil, uuid.New())
+	charID := uuid.New()
+	svc.Join(context.Background(), client, character.Character{ID: charID, Name: "Aria", ZoneID: "starter-zone", PosX: 20.5, PosY: 3.5})
+	svc.UnregisterClient(context.Background(), client)
+
+	// The tick sends acks after unlocking the zone, by which time the
+	// player may have disconnected and had their channel closed.
+	z.sendToPlayer(charID, map[string]any{"type": "player_moved", "player_id": charID})
+}
+
+func TestMobPathsAroundWalls(t *testing.T) {
+	dir := t.TempDir()
+	writeTestMap(t, dir, "ruins", map[string]any{
+		"rows": []string{
+			"##########",
+			"#........#",
+			"#........#",
+			"#.######.#",
+			"#........#",
+			"#........#",
+			"#........#",
+			"#........#",
+			"#........#",
+			"##########",
+		},
+		"spawn_groups": []map[string]any{{"id": "mob-rat", "template": "rat", "x": 4.5, "y": 1.5, "patrol_radius": 8}},
+	})
+	writeTestMobTemplates(t, dir, map[string]any{"id": "rat", "name": "Rat", "hp": 30, "damage": 1})
+	svc := NewService(zerolog.Nop(), nil, nil, "ruins", 10, dir)
+	z := svc.zones["ruins"]
+
+	path, ok := z.findPath(4.5, 1.5, 4.5, 5.5, mobCollisionRadius, func(float64, float64) bool { return true })
+	if !ok || len(path) == 0 {
+		t.Fatalf("expected a path around the wall, got ok=%v path=%v", ok, path)
+	}
+	for _, p := range path {
+		if !z.isWalkableWithRadius(p.X, p.Y, mobCollisionRadius) {
+			t.Fatalf("path crosses blocked tile at %+v", p)
+		}
+	}
+
+	client := svc.RegisterClient(nil, uuid.New())
+	charID := uuid.New()
+	svc.Join(context.Background(), client, character.Character{ID: charID, Name: "Aria", ZoneID: "ruins", PosX: 4.5, PosY: 5.5})
+	<-client.Send
+
+	for i := 0; i < 80; i++ {
+		svc.tickZone(z)
+	}
+	z.mu.RLock()
+	defer z.mu.RUnlock()
+	mob, pr := z.mobs["mob-rat-1"], z.players[charID]
+	if d := distance(mob.State.X, mob.State.Y, pr.State.X, pr.State.Y); d > mob.Template.AttackRange {
+		t.Fatalf("expected mob to reach the player around the wall, still %.2f away at (%.2f,%.2f)", d, mob.State.X, mob.State.Y)
+	}
+}
+
+func TestMobEvadesAndResetsWhenLeashed(t *testing.T) {
+	dir := t.TempDir()
+	writeTestMap(t, dir, "field", map[string]any{
+		"width":  30,
+		"height": 5,
+		"rows": []string{
+			"##############################",
+			"#............................#",
+			"#............................#",
+			"#............................#",
+			"##############################",
+		},
+		"spawn_groups": []map[string]any{{"id": "mob-boar", "template": "boar", "x": 3.5, "y": 2.5, "patrol_radius": 2, "leash_radius": 6}},
+	})
+	writeTestMobTemplates(t, dir, map[string]any{"id": "boar", "name": "Boar", "hp": 80, "damage": 1})
+	svc := NewService(zerolog.Nop(), nil, nil, "field", 10, dir)
+	z := svc.zones["field"]
+	client := svc.RegisterClient(nil, uuid.New())
+	charID := uuid.New()
+	svc.Join(context.Background(), client, character.Character{ID: charID, Name: "Aria", ZoneID: "field", PosX: 7.5, PosY: 2.5})
+	<-client.Send
+
+	svc.tickZone(z)
+	z.mu.Lock()
+	mob := z.mobs["mob-boar-1"]
+	if mob.State.AIState != domainworld.MobAIStateChase || mob.TargetID != charID {
+		z.mu.Unlock()
+		t.Fatalf("expected boar to chase the player, got state %q", mob.State.AIState)
+	}
+	mob.State.HP = 20
+	pr := z.players[charID]
+	pr.State.X = 20.5
+	z.playerGrid.Upsert(charID, pr.State.X, pr.State.Y)
+	z.mu.Unlock()
+
+	svc.tickZone(z)
+	z.mu.RLock()
+	state, hp := mob.State.AIState, mob.State.HP
+	z.mu.RUnlock()
+	if state != domainworld.MobAIStateEvade || hp != 80 {
+		t.Fatalf("expected boar to evade at full hp, got state %q hp %d", state, hp)
+	}
+
+	for i := 0; i < mobEvadeMaxTicks && state == domainworld.MobAIStateEvade; i++ {
+		svc.tickZone(z)
+		z.mu.RLock()
+		state = mob.State.AIState
+		z.mu.RUnlock()
+	}
+	z.mu.RLock()
+	defer z.mu.RUnlock()
+	if state == domainworld.MobAIStateEvade || mob.State.X != mob.SpawnX || mob.State.Y != mob.SpawnY {
+		t.Fatalf("expected boar back at spawn out of evade, got state %q at (%v,%v)", state, mob.State.X, mob.State.Y)
+	}
+}
+
+func TestMobTargetsHighestThreat(t *testing.T) {
+	dir := t.TempDir()
+	writeTestMap(t, dir, "arena", map[string]any{
+		"spawn_groups": []map[string]any{{"id": "mob-golem", "template": "golem", "x": 5.5, "y": 5.5, "patrol_radius": 3}},
+	})
+	writeTestMobTemplates(t, dir, map[string]any{"id": "golem", "name": "Golem", "hp": 500, "damage": 1})
+	svc := NewService(zerolog.Nop(), nil, nil, "arena", 10, dir)
+	z := svc.zones["arena"]
+
+	tank := svc.RegisterClient(nil, uuid.New())
+	tankID := uuid.New()
+	svc.Join(context.Background(), tank, character.Character{ID: tankID, Name: "Tank", ZoneID: "arena", PosX: 5.5, PosY: 6.3})
+	dps := svc.RegisterClient(nil, uuid.New())
+	dpsID := uuid.New()
+	svc.Join(context.Background(), dps, character.Character{ID: dpsID, Name: "Dps", ZoneID: "arena", PosX: 6.6, PosY: 5.5})
+
+	svc.tickZone(z)
+	z.mu.RLock()
+	mob := z.mobs["mob-golem-1"]
+	first := mob.TargetID
+	z.mu.RUnlock()
+	if first != tankID {
+		t.Fatalf("expected closest player to pull aggro first, got %v", first)
+	}
+
+	svc.Attack(dps, "mob-golem-1")
+	svc.tickZone(z)
+	z.mu.RLock()
+	second := mob.TargetID
+	z.mu.RUnlock()
+	if second != dpsID {
+		t.Fatalf("expected damage dealer to take aggro, got %v", second)
+	}
+
+	svc.UnregisterClient(context.Background(), dps)
+	svc.tickZone(z)
+	z.mu.RLock()
+	third, threat := mob.TargetID, len(mob.Threat)
+	z.mu.RUnlock()
+	if third != tankID || threat != 1 {
+		t.Fatalf("expected aggro to fall back to tank with one threat entry, got %v (%d entries)", third, threat)
+	}
+}
+
+func TestSpawnGroupsInstantiateMobTemplates(t *testing.T) {
+	dir := t.TempDir()
+	writeTestMap(t, dir, "den", map[string]any{
+		"spawn_groups": []map[string]any{{"id": "mob-wolf", "template": "wolf", "x": 5, "y": 5, "count": 3, "radius": 1.5, "patrol_radius": 2}},
+	})
+	writeTestMobTemplates(t, dir, map[string]any{"id": "wolf", "name": "Wolf", "level": 4, "hp": 110, "damage": 13, "move_speed": 0.25, "xp_reward": 70})
+	svc := NewService(zerolog.Nop(), nil, nil, "den", 10, dir)
+	z := svc.zones["den"]
+	if len(z.mobs) != 3 {
+		t.Fatalf("expected 3 mobs from spawn group, got %d", len(z.mobs))
+	}
+	seen := make(map[[2]float64]bool)
+	for i := 1; i <= 3; i++ {
+		mob, ok := z.mobs[fmt.Sprintf("mob-wolf-%d", i)]
+		if !ok {
+			t.Fatalf("missing mob-wolf-%d", i)
+		}
+		st := mob.State
+		if st.TemplateID != "wolf" || st.Name != "Wolf" || st.Level != 4 || st.MaxHP != 110 || st.Damage != 13 {
+			t.Fatalf("mob state not built from template: %+v", st)
+		}
+		if mob.Template.MoveSpeed != 0.25 || mob.Template.AggroRange != mobDefaultAggroRange || mob.Template.RespawnTicks != mobDefaultRespawnTicks {
+			t.Fatalf("unexpected template tuning: %+v", mob.Template)
+		}
+		seen[[2]float64{st.X, st.Y}] = true
+	}
+	if len(seen) != 3 {
+		t.Fatalf("expected group members at distinct positions, got %v", seen)
+	}
+
+	writeTestMap(t, dir, "den", map[string]any{
+		"spawn_groups": []map[string]any{{"id": "mob-ghost", "template": "ghost", "x": 5, "y": 5}},
+	})
+	if _, err := loadZoneDir(filepath.Join(dir, "maps"), catalog{}); err == nil {
+		t.Fatalf("expected unknown template to fail map loading")
+	}
+}
+
+func TestKillingMobRollsLootTable(t *testing.T) {
+	dir := t.TempDir()
+	writeTestMap(t, dir, "pit", map[string]any{
+		"spawn_groups": []map[string]any{{"id": "mob-beetle", "template": "beetle", "x": 5.5, "y": 5.5}},
+	})
+	writeTestMobTemplates(t, dir, map[string]any{
+		"id": "beetle", "name": "Beetle", "hp": 10, "damage": 1,
+		"loot": map[string]any{
+			"gold_min": 7,
+			"rolls":    2,
+			"drops":    []map[string]any{{"item_id": "chitin", "weight": 1, "min_quantity": 2, "rarity": "uncommon"}},
+		},
+	})
+	svc := NewService(zerolog.Nop(), nil, nil, "pit", 10, dir)
+	z := svc.zones["pit"]
+	client := svc.RegisterClient(nil, uuid.New())
+	charID := uuid.New()
+	svc.Join(context.Background(), client, character.Character{ID: charID, Name: "Aria", ZoneID: "pit", PosX: 5.5, PosY: 6.3, Progress: character.Progress{Gold: 100}})
+	drainTypes(client)
+
+	svc.Attack(client, "mob-beetle-1")
+	var dropped map[string]any
+	for len(client.Send) > 0 {
+		var msg map[string]any
+		if err := json.Unmarshal(<-client.Send, &msg); err == nil && msg["type"] == "loot_dropped" {
+			dropped = msg
+		}
+	}
+	if dropped == nil || dropped["gold"] != float64(7) {
+		t.Fatalf("expected loot_dropped with 7 gold, got %v", dropped)
+	}
+
+	state, _ := svc.ZoneState("pit")
+	if state.Players[0].Gold != 107 {
+		t.Fatalf("expected gold to be awarded to the killer, got %d", state.Players[0].Gold)
+	}
+	if len(state.Loot) != 1 {
+		t.Fatalf("expected one ground loot pile, got %+v", state.Loot)
+	}
+	pile := state.Loot[0]
+	want := domainworld.LootItem{ItemID: "chitin", Quantity: 4, Rarity: domainworld.ItemRarityUncommon}
+	if pile.OwnerID != charID || len(pile.Items) != 1 || pile.Items[0] != want {
+		t.Fatalf("unexpected ground loot %+v", pile)
+	}
+
+	for i := 0; i < groundLootTicks; i++ {
+		svc.tickZone(z)
+	}
+	if state, _ := svc.ZoneState("pit"); len(state.Loot) != 0 {
+		t.Fatalf("expected ground loot to expire, got %+v", state.Loot)
+	}
+}
+
+func writeTestItems(t *testing.T, dir string, items ...map[string]any) {
+	t.Helper()
+	b, err := json.Marshal(map[string]any{"items": items})
+	if err != nil {
+		t.Fatalf("marshal items: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "items.json"), b, 0o644); err != nil {
+		t.Fatalf("write items: %v", err)
+	}
+}
+
+// writeTestClasses copies the shipped class catalog, since only the default
+// class exists without one.
+func writeTestClasses(t *testing.T, dir string) {
+	t.Helper()
+	b, err := os.ReadFile("../../../data/classes.json")
+	if err != nil {
+		t.Fatalf("read classes: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "classes.json"), b, 0o644); err != nil {
+		t.Fatalf("write classes: %v", err)
+	}
+}
+
+func TestInventoryPickupUseDropAndPersist(t *testing.T) {
+	dir := t.TempDir()
+	writeTestMap(t, dir, "camp", nil)
+	writeTestItems(t, dir,
+		map[string]any{"id": "potion", "name": "Potion", "kind": "consumable", "max_stack": 5, "heal": 30},
+		map[string]any{"id": "gel", "name": "Gel", "max_stack": 3},
+	)
+	store := newFakeCharacterStore()
+	charID := uuid.New()
+	store.inventory[charID] = []character.InventorySlot{{Slot: 4, ItemID: "potion", Quantity: 2}, {Slot: 6, ItemID: "unknown", Quantity: 1}}
+	svc := NewService(zerolog.Nop(), nil, store, "camp", 10, dir)
+	z := svc.zones["camp"]
+
+	client := svc.RegisterClient(nil, uuid.New())
+	svc.Join(context.Background(), client, character.Character{ID: charID, Name: "Aria", ZoneID: "camp", PosX: 3.5, PosY: 3.5, Progress: character.Progress{HP: 50, MaxHP: 100}})
+	var welcome struct {
+		Inventory struct {
+			Capacity int                       `json:"capacity"`
+			Slots    []character.InventorySlot `json:"slots"`
+		} `json:"inventory"`
+	}
+	if err := json.Unmarshal(<-client.Send, &welcome); err != nil {
+		t.Fatalf("unmarshal welcome: %v", err)
+	}
+	if welcome.Inventory.Capacity != character.InventoryCapacity || len(welcome.Inventory.Slots) != 1 || welcome.Inventory.Slots[0].Slot != 4 {
+		t.Fatalf("expected saved inventory in welcome, got %+v", welcome.Inventory)
+	}
+
+	z.mu.Lock()
+	pile := z.spawnLootLocked(3.5, 4.5, charID, []domainworld.LootItem{{ItemID: "gel", Quantity: 4}})
+	z.mu.Unlock()
+	svc.PickupLoot(client, pile.State.ID)
+	svc.UseItem(client, 4)
+	svc.MoveItem(client, 4, 0)
+	svc.DropItem(client, 1, 0)
+
+	z.mu.RLock()
+	pr := z.players[charID]
+	hp, slots := pr.State.HP, pr.Inventory.Slots()
+	piles := z.groundLootLocked()
+	z.mu.RUnlock()
+	if hp != 80 {
+		t.Fatalf("expected potion to heal to 80, got %d", hp)
+	}
+	want := []character.InventorySlot{{Slot: 0, ItemID: "potion", Quantity: 1}, {Slot: 4, ItemID: "gel", Quantity: 3}}
+	if len(slots) != len(want) || slots[0] != want[0] || slots[1] != want[1] {
+		t.Fatalf("unexpected inventory %+v", slots)
+	}
+	if len(piles) != 1 || piles[0].OwnerID != uuid.Nil || piles[0].Items[0].ItemID != "gel" || piles[0].Items[0].Quantity != 1 {
+		t.Fatalf("expected dropped gel on the ground, got %+v", piles)
+	}
+
+	var saved []character.InventorySlot
+	for i := 0; i < 100; i++ {
+		store.mu.Lock()
+		saved = store.inventory[charID]
+		store.mu.Unlock()
+		if len(saved) == 2 && saved[0] == want[0] && saved[1] == want[1] {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if len(saved) != 2 || saved[0] != want[0] || saved[1] != want[1] {
+		t.Fatalf("expected inventory changes to be saved without waiting for logout, got %+v", saved)
+	}
+	svc.UnregisterClient(context.Background(), client)
+}
+
+func TestEquipmentDrivesDerivedStats(t *testing.T) {
+	dir := t.TempDir()
+	writeTestMap(t, dir, "armory", map[string]any{
+		"spawn_groups": []map[string]any{{"id": "mob-dummy", "template": "dummy", "x": 5.5, "y": 5.5}},
+	})
+	writeTestMobTemplates(t, dir, map[string]any{"id": "dummy", "name": "Dummy", "hp": 500, "damage": 20, "aggro_range": 0.01})
+	writeTestItems(t, dir,
+		map[string]any{"id": "sword", "name": "Sword", "kind": "weapon", "stats": map[string]any{"attack_power": 10}},
+		map[string]any{"id": "plate", "name": "Plate", "kind": "armor", "slot": "chest", "stats": map[string]any{"armor": 50, "max_hp": 30}},
+		map[string]any{"id": "axe", "name": "Axe", "kind": "weapon", "stats": map[string]any{"attack_power": 15}},
+	)
+	store := newFakeCharacterStore()
+	charID := uuid.New()
+	store.inventory[charID] = []character.InventorySlot{{Slot: 0, ItemID: "sword", Quantity: 1}, {Slot: 1, ItemID: "axe", Quantity: 1}}
+	store.equipment[charID] = []character.EquippedItem{{Slot: "chest", ItemID: "plate"}}
+	svc := NewService(zerolog.Nop(), nil, store, "armory", 10, dir)
+	z := svc.zones["armory"]
+
+	client := svc.RegisterClient(nil, uuid.New())
+	svc.Join(context.Background(), client, character.Character{ID: charID, Name: "Aria", Class: "adventurer", ZoneID: "armory", PosX: 5.5, PosY: 6.3})
+	player := func() domainworld.PlayerState {
+		z.mu.RLock()
+		defer z.mu.RUnlock()
+		return z.players[charID].State
+	}
+	if p := player(); p.Stats != (domainworld.Stats{AttackPower: 20, Armor: 50, MaxHP: 130, HPRegen: 2}) || p.MaxHP != 130 {
+		t.Fatalf("expected class base plus saved plate, got %+v", p.Stats)
+	}
+
+	svc.Equip(client, 0)
+	svc.Equip(client, 1)
+	if p := player(); p.Stats.AttackPower != 35 || p.Equipment[domainworld.EquipSlotWeapon] != "axe" {
+		t.Fatalf("expected axe to replace sword, got %+v %v", p.Stats, p.Equipment)
+	}
+
+	svc.Attack(client, "mob-dummy-1")
+	z.mu.Lock()
+	mob, pr := z.mobs["mob-dummy-1"], z.players[charID]
+	mobHP := mob.State.HP
+	z.applyMobAttackLocked(mob, mob.Template.Attacks[0], pr)
+	hp := pr.State.HP
+	z.mu.Unlock()
+	if mobHP != 465 {
+		t.Fatalf("expected attack power 35 to hit for 35, mob at %d", mobHP)
+	}
+	if hp != 100-10 {
+		t.Fatalf("expected 50 armor to halve a 20 damage hit, hp %d", hp)
+	}
+
+	svc.Unequip(client, domainworld.EquipSlotChest)
+	if p := player(); p.MaxHP != 100 || p.HP != 90 || p.Stats.Armor != 0 {
+		t.Fatalf("expected plate removal to drop max hp and armor, got %+v", p)
+	}
+
+	var equipped []character.EquippedItem
+	var slots []character.InventorySlot
+	for i := 0; i < 100; i++ {
+		store.mu.Lock()
+		equipped, slots = store.equipment[charID], store.inventory[charID]
+		store.mu.Unlock()
+		if len(equipped) == 1 && equipped[0].ItemID == "axe" && len(slots) == 2 {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if len(equipped) != 1 || equipped[0] != (character.EquippedItem{Slot: "weapon", ItemID: "axe"}) {
+		t.Fatalf("expected axe to be saved as equipped without waiting for logout, got %+v", equipped)
+	}
+	if len(slots) != 2 {
+		t.Fatalf("expected sword and plate back in the bags, got %+v", slots)
+	}
+
+	z.mu.Lock()
+	events := z.killPlayerLocked(z.players[charID])
+	z.mu.Unlock()
+	z.dispatch(events)
+	drainTypes(client)
+	svc.Unequip(client, domainworld.EquipSlotWeapon)
+	if types := drainTypes(client); !contains(types, "error") || player().Equipment[domainworld.EquipSlotWeapon] != "axe" {
+		t.Fatalf("expected the dead to be unable to change gear, got %v", types)
+	}
+	svc.UnregisterClient(context.Background(), client)
+}
+
+func TestMerchantBuyAndSell(t *testing.T) {
+	dir := t.TempDir()
+	writeTestMap(t, dir, "market", map[string]any{
+		"npcs": []map[string]any{{"id": "npc-trader", "name": "Trader", "x": 3, "y": 3, "interactions": []string{"trade"}, "stock": []map[string]any{{"item_id": "potion", "price": 30}}}},
+	})
+	writeTestItems(t, dir, map[string]any{"id": "potion", "name": "Potion", "kind": "consumable", "max_stack": 5, "value": 20, "heal": 30})
+	store := newFakeCharacterStore()
+	svc := NewService(zerolog.Nop(), nil, store, "market", 10, dir)
+	z := svc.zones["market"]
+
+	client := svc.RegisterClient(nil, uuid.New())
+	charID := uuid.New()
+	svc.Join(context.Background(), client, character.Character{ID: charID, Name: "Aria", ZoneID: "market", PosX: 3.5, PosY: 3.5, Progress: character.Progress{Gold: 100}})
+	drainTypes(client)
+
+	svc.Interact(client, "npc-trader", "buy", InteractArgs{ItemID: "potion", Quantity: 3})
+	svc.Interact(client, "npc-trader", "buy", InteractArgs{ItemID: "potion", Quantity: 1})
+	if types := drainTypes(client); !contains(types, "error") {
+		t.Fatalf("expected second purchase to fail for lack of gold, got %v", types)
+	}
+	svc.Interact(client, "npc-trader", "sell", InteractArgs{Slot: 0, Quantity: 2})
+
+	z.mu.RLock()
+	pr := z.players[charID]
+	gold, slots := pr.State.Gold, pr.Inventory.Slots()
+	z.mu.RUnlock()
+	if gold != 100-90+20 || len(slots) != 1 || slots[0].Quantity != 1 {
+		t.Fatalf("expected 30 gold and one potion left, got %d gold and %+v", gold, slots)
+	}
+
+	z.mu.Lock()
+	pr.State.X, pr.State.Y = 8.5, 8.5
+	z.mu.Unlock()
+	svc.Interact(client, "npc-trader", "sell", InteractArgs{Slot: 0})
+	if types := drainTypes(client); !contains(types, "error") {
+		t.Fatalf("expected trading out of range to fail, got %v", types)
+	}
+
+	writeTestMap(t, dir, "bazaar", map[string]any{
+		"npcs": []map[string]any{{"id": "npc-fence", "name": "Fence", "x": 3, "y": 3, "stock": []map[string]any{{"item_id": "potion", "price": 9}}}},
+	})
+	if _, err := loadZoneDir(filepath.Join(dir, "maps"), catalog{items: svc.items}); err == nil {
+		t.Fatalf("expected stock priced below the sell price to fail map loading")
+	}
+
+	for i := 0; i < 100; i++ {
+		store.mu.Lock()
+		saved, savedSlots := store.progress[charID].Gold, store.inventory[charID]
+		store.mu.Unlock()
+		if saved == 30 && len(savedSlots) == 1 && savedSlots[0].Quantity == 1 {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("expected trade result to be persisted")
+}
+
+func TestFailedTradeSaveIsReportedToPlayer(t *testing.T) {
+	dir := t.TempDir()
+	writeTestMap(t, dir, "market", map[string]any{
+		"npcs": []map[string]any{{"id": "npc-trader", "name": "Trader", "x": 3, "y": 3, "interactions": []string{"trade"}, "stock": []map[string]any{{"item_id": "potion", "price": 30}}}},
+	})
+	writeTestItems(t, dir, map[string]any{"id": "potion", "name": "Potion", "kind": "consumable", "max_stack": 5, "value": 20, "heal": 30})
+	store := newFakeCharacterStore()
+	store.failSaves = true
+	svc := NewService(zerolog.Nop(), nil, store, "market", 10, dir)
+
+	client := svc.RegisterClient(nil, uuid.New())
+	svc.Join(context.Background(), client, character.Character{ID: uuid.New(), Name: "Aria", ZoneID: "market", PosX: 3.5, PosY: 3.5, Progress: character.Progress{Gold: 100}})
+	drainTypes(client)
+
+	svc.Interact(client, "npc-trader", "buy", InteractArgs{ItemID: "potion", Quantity: 1})
+	deadline := time.After(time.Second)
+	for {
+		select {
+		case raw := <-client.Send:
+			var msg struct {
+				Type    string `json:"type"`
+				Message string `json:"message"`
+			}
+			_ = json.Unmarshal(raw, &msg)
+			if msg.Type == "error" && strings.Contains(msg.Message, "failed to save") {
+				svc.UnregisterClient(context.Background(), client)
+				return
+			}
+		case <-deadline:
+			t.Fatalf("expected the player to be told the trade was not saved")
+		}
+	}
+}
+
+func TestQuestTurnInIsSavedTogetherWithItsRewards(t *testing.T) {
+	dir := t.TempDir()
+	writeTestMap(t, dir, "farm", map[string]any{
+		"npcs": []map[string]any{{"id": "npc-farmer", "name": "Farmer", "x": 3, "y": 3, "interactions": []string{"quest"}}},
+	})
+	writeTestItems(t, dir, map[string]any{"id": "tail", "name": "Rat Tail", "kind": "material", "max_stack": 10})
+	b, _ := json.Marshal(map[string]any{"quests": []map[string]any{{
+		"id": "tails", "name": "Tails", "giver": "npc-farmer",
+		"objectives": []map[string]any{{"type": "collect", "target": "tail", "count": 2}},
+		"rewards":    map[string]any{"gold": 10},
+	}}})
+	if err := os.WriteFile(filepath.Join(dir, "quests.json"), b, 0o644); err != nil {
+		t.Fatalf("write quests: %v", err)
+	}
+	store := newFakeCharacterStore()
+	svc := NewService(zerolog.Nop(), nil, store, "farm", 10, dir)
+
+	client := svc.RegisterClient(nil, uuid.New())
+	charID := uuid.New()
+	store.inventory[charID] = []character.InventorySlot{{Slot: 0, ItemID: "tail", Quantity: 2}}
+	svc.Join(context.Background(), client, character.Character{ID: charID, Name: "Aria", ZoneID: "farm", PosX: 3.5, PosY: 3.5})
+	svc.Interact(client, "npc-farmer", "accept", InteractArgs{QuestID: "tails"})
+	for i := 0; i < 100; i++ {
+		store.mu.Lock()
+		accepted := len(store.quests[charID]) == 1
+		store.failQuests = accepted
+		store.mu.Unlock()
+		if accepted {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	drainTypes(client)
+
+	svc.Interact(client, "npc-farmer", "turn_in", InteractArgs{QuestID: "tails"})
+	deadline := time.After(time.Second)
+	for reported := false; !reported; {
+		select {
+		case raw := <-client.Send:
+			reported = strings.Contains(string(raw), "failed to save")
+		case <-deadline:
+			t.Fatalf("expected the player to be told the turn-in was not saved")
+		}
+	}
+	store.mu.Lock()
+	gold, slots, quests := store.progress[charID].Gold, store.inventory[charID], store.quests[charID]
+	store.mu.Unlock()
+	if gold != 0 || len(slots) != 1 || slots[0].Quantity != 2 || len(quests) != 1 || quests[0].Status != character.QuestStatusActive {
+		t.Fatalf("expected neither the rewards nor the completion to be saved, got %d gold, %+v, %+v", gold, slots, quests)
+	}
+	svc.UnregisterClient(context.Background(), client)
+}
+
+func TestTalkObjectiveNeedsTheNPCInRange(t *testing.T) {
+	dir := t.TempDir()
+	writeTestMap(t, dir, "farm", map[string]any{
+		"npcs": []map[string]any{{"id": "npc-farmer", "name": "Farmer", "x": 3, "y": 3, "interactions": []string{"talk", "quest"}}},
+	})
+	b, _ := json.Marshal(map[string]any{"quests": []map[string]any{{
+		"id": "greet", "name": "Greet", "giver": "npc-farmer",
+		"objectives": []map[string]any{{"type": "talk", "target": "npc-farmer"}},
+	}}})
+	if err := os.WriteFile(filepath.Join(dir, "quests.json"), b, 0o644); err != nil {
+		t.Fatalf("write quests: %v", err)
+	}
+	svc := NewService(zerolog.Nop(), nil, nil, "farm", 10, dir)
+	z := svc.zones["farm"]
+
+	client := svc.RegisterClient(nil, uuid.New())
+	charID := uuid.New()
+	svc.Join(context.Background(), client, character.Character{ID: charID, Name: "Aria", ZoneID: "farm", PosX: 3.5, PosY: 3.5})
+	svc.Interact(client, "npc-farmer", "accept", InteractArgs{QuestID: "greet"})
+	setX := func(x float64) {
+		z.mu.Lock()
+		z.players[charID].State.X = x
+		z.mu.Unlock()
+	}
+	setX(9.5)
+	drainTypes(client)
+	svc.Interact(client, "npc-farmer", "talk", InteractArgs{})
+	if types := drainTypes(client); contains(types, "quest_progress") {
+		t.Fatalf("expected talking from afar not to count, got %v", types)
+	}
+	setX(3.5)
+	svc.Interact(client, "npc-farmer", "talk", InteractArgs{})
+	if types := drainTypes(client); !contains(types, "quest_progress") {
+		t.Fatalf("expected talking up close to count, got %v", types)
+	}
+}
+
+func TestQuestAcceptProgressAndTurnIn(t *testing.T) {
+	dir := t.TempDir()
+	writeTestMap(t, dir, "farm", map[string]any{
+		"npcs":         []map[string]any{{"id": "npc-farmer", "name": "Farmer", "x": 3, "y": 3, "interactions": []string{"talk", "quest"}}},
+		"spawn_groups": []map[string]any{{"id": "mob-rat", "template": "rat", "x": 4, "y": 4, "count": 2, "radius": 0.5}},
+	})
+	writeTestMobTemplates(t, dir, map[string]any{"id": "rat", "hp": 1})
+	writeTestItems(t, dir,
+		map[string]any{"id": "tail", "name": "Rat Tail", "kind": "material", "max_stack": 10},
+		map[string]any{"id": "potion", "name": "Potion", "kind": "consumable", "max_stack": 5, "heal": 30},
+	)
+	b, _ := json.Marshal(map[string]any{"quests": []map[string]any{{
+		"id": "pests", "name": "Pests", "giver": "npc-farmer",
+		"objectives": []map[string]any{{"type": "kill", "target": "rat", "count": 2}, {"type": "collect", "target": "tail", "count": 2}},
+		"rewards":    map[string]any{"xp": 150, "gold": 10, "items": []map[string]any{{"item_id": "potion", "quantity": 1}}},
+	}}})
+	if err := os.WriteFile(filepath.Join(dir, "quests.json"), b, 0o644); err != nil {
+		t.Fatalf("write quests: %v", err)
+	}
+	store := newFakeCharacterStore()
+	svc := NewService(zerolog.Nop(), nil, store, "farm", 10, dir)
+	z := svc.zones["farm"]
+
+	client := svc.RegisterClient(nil, uuid.New())
+	charID := uuid.New()
+	store.inventory[charID] = []character.InventorySlot{{Slot: 0, ItemID: "tail", Quantity: 3}}
+	svc.Join(context.Background(), client, character.Character{ID: charID, Name: "Aria", ZoneID: "farm", PosX: 3.5, PosY: 3.5})
+	drainTypes(client)
+
+	svc.Interact(client, "npc-farmer", "turn_in", InteractArgs{QuestID: "pests"})
+	svc.Interact(client, "npc-farmer", "accept", InteractArgs{QuestID: "pests"})
+	svc.Interact(client, "npc-farmer", "turn_in", InteractArgs{QuestID: "pests"})
+	if types := drainTypes(client); !contains(types, "quest_log") || len(types) != 4 || types[0] != "error" || types[3] != "error" {
+		t.Fatalf("expected turn-in to fail before accepting and before the objectives are done, got %v", types)
+	}
+
+	svc.Attack(client, "mob-rat-1")
+	waitGlobalCooldown(svc, z)
+	svc.Attack(client, "mob-rat-2")
+	if types := drainTypes(client); !contains(types, "quest_progress") {
+		t.Fatalf("expected kills to report quest progress, got %v", types)
+	}
+	svc.Interact(client, "npc-farmer", "turn_in", InteractArgs{QuestID: "pests"})
+
+	z.mu.RLock()
+	pr := z.players[charID]
+	level, gold, slots, status := pr.State.Level, pr.State.Gold, pr.Inventory.Slots(), pr.Quests["pests"].Status
+	z.mu.RUnlock()
+	if level != 2 || gold != 10 || status != character.QuestStatusCompleted {
+		t.Fatalf("expected level 2, 10 gold and a completed quest, got level %d, %d gold, %q", level, gold, status)
+	}
+	if len(slots) != 2 || slots[0].ItemID != "tail" || slots[0].Quantity != 1 || slots[1].ItemID != "potion" {
+		t.Fatalf("expected two tails consumed and a potion rewarded, got %+v", slots)
+	}
+	svc.Interact(client, "npc-farmer", "accept", InteractArgs{QuestID: "pests"})
+	if types := drainTypes(client); !contains(types, "error") {
+		t.Fatalf("expected a completed quest not to be offered again, got %v", types)
+	}
+
+	svc.UnregisterClient(context.Background(), client)
+	store.mu.Lock()
+	saved := store.quests[charID]
+	store.mu.Unlock()
+	if len(saved) != 1 || saved[0].QuestID != "pests" || saved[0].Status != character.QuestStatusCompleted {
+		t.Fatalf("expected the quest log to be persisted, got %+v", saved)
+	}
+}
+
+func TestDialogueChoicesFollowConditionsAndActions(t *testing.T) {
+	dir := t.TempDir()
+	writeTestMap(t, dir, "village", map[string]any{
+		"npcs": []map[string]any{{"id": "npc-elder", "name": "Elder", "x": 3, "y": 3, "interactions": []string{"talk", "quest"}, "dialogue_id": "elder"}},
+	})
+	writeTestItems(t, dir, map[string]any{"id": "herb", "name": "Herb", "kind": "material", "max_stack": 10})
+	files := map[string]any{
+		"quests.json": map[string]any{"quests": []map[string]any{{
+			"id": "herbs", "giver": "npc-elder",
+			"objectives": []map[string]any{{"type": "collect", "target": "herb", "count": 1}},
+			"rewards":    map[string]any{"gold": 5},
+		}, {
+			"id": "roots", "giver": "npc-elder",
+			"objectives": []map[string]any{{"type": "collect", "target": "herb", "count": 5}},
+		}}},
+		"dialogues.json": map[string]any{"dialogues": []map[string]any{{
+			"id": "elder", "start": "greet",
+			"nodes": map[string]any{
+				"greet": map[string]any{"text": "Hello.", "choices": []map[string]any{
+					{"text": "Need help?", "next": "offer", "conditions": []map[string]any{{"type": "quest_status", "quest_id": "herbs", "status": "available"}}},
+					{"text": "Here are your herbs.", "conditions": []map[string]any{{"type": "quest_status", "quest_id": "herbs", "status": "ready"}}, "actions": []map[string]any{{"type": "turn_in_quest", "quest_id": "herbs"}}},
+					{"text": "I am rich.", "conditions": []map[string]any{{"type": "min_gold", "value": 1000}}},
+					{"text": "Bye."},
+					{"text": "Roots? Done already.", "conditions": []map[string]any{{"type": "quest_status", "quest_id": "roots", "status": "available"}}, "actions": []map[string]any{{"type": "grant_quest", "quest_id": "roots"}, {"type": "turn_in_quest", "quest_id": "roots"}}},
+				}},
+				"offer": map[string]any{"text": "Fetch me a herb.", "choices": []map[string]any{
+					{"text": "Will do.", "actions": []map[string]any{{"type": "grant_quest", "quest_id": "herbs"}}},
+				}},
+			},
+		}}},
+	}
+	for name, v := range files {
+		b, _ := json.Marshal(v)
+		if err := os.WriteFile(filepath.Join(dir, name), b, 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	store := newFakeCharacterStore()
+	svc := NewService(zerolog.Nop(), nil, store, "village", 10, dir)
+	z := svc.zones["village"]
+
+	client := svc.RegisterClient(nil, uuid.New())
+	charID := uuid.New()
+	store.inventory[charID] = []character.InventorySlot{{Slot: 0, ItemID: "herb", Quantity: 1}}
+	svc.Join(context.Background(), client, character.Character{ID: charID, Name: "Aria", ZoneID: "village", PosX: 3.5, PosY: 3.5})
+	drainTypes(client)
+
+	choiceIndexes := func() []int {
+		t.Helper()
+		var indexes []int
+		for len(client.Send) > 0 {
+			var msg struct {
+				Type   string `json:"type"`
+				Node   struct{ Choices []struct{ Index int } }
+				Result struct {
+					Node struct{ Choices []struct{ Index int } }
+				}
+			}
+			if err := json.Unmarshal(<-client.Send, &msg); err != nil {
+				t.Fatalf("decode message: %v", err)
+			}
+			choices := msg.Node.Choices
+			if msg.Type == "npc_response" {
+				choices = msg.Result.Node.Choices
+			}
+			indexes = indexes[:0]
+			for _, c := range choices {
+				indexes = append(indexes, c.Index)
+			}
+		}
+		return indexes
+	}
+
+	svc.Interact(client, "npc-elder", "talk", InteractArgs{})
+	if got := choiceIndexes(); fmt.Sprint(got) != "[0 3 4]" {
+		t.Fatalf("expected only the offer and goodbye choices, got %v", got)
+	}
+	svc.DialogueChoice(client, "npc-elder", 1)
+	if types := drainTypes(client); len(types) != 1 || types[0] != "error" {
+		t.Fatalf("expected a hidden choice to be rejected, got %v", types)
+	}
+	svc.DialogueChoice(client, "npc-elder", 0)
+	svc.DialogueChoice(client, "npc-elder", 0)
+	if types := drainTypes(client); !contains(types, "dialogue_end") || !contains(types, "quest_log") {
+		t.Fatalf("expected the quest to be granted and the dialogue to end, got %v", types)
+	}
+
+	svc.Interact(client, "npc-elder", "talk", InteractArgs{})
+	if got := choiceIndexes(); fmt.Sprint(got) != "[1 3 4]" {
+		t.Fatalf("expected the turn-in and goodbye choices, got %v", got)
+	}
+	svc.DialogueChoice(client, "npc-elder", 1)
+
+	z.mu.RLock()
+	pr := z.players[charID]
+	gold, status, session := pr.State.Gold, pr.Quests["herbs"].Status, pr.Dialogue
+	z.mu.RUnlock()
+	if gold != 5 || status != character.QuestStatusCompleted || session != nil {
+		t.Fatalf("expected the quest turned in through dialogue, got %d gold, %q, session %+v", gold, status, session)
+	}
+
+	svc.Interact(client, "npc-elder", "talk", InteractArgs{})
+	drainTypes(client)
+	z.mu.Lock()
+	pr.State.Dead = true
+	z.mu.Unlock()
+	svc.DialogueChoice(client, "npc-elder", 4)
+	if types := drainTypes(client); len(types) != 1 || types[0] != "error" {
+		t.Fatalf("expected the dead to be unable to pick a dialogue choice, got %v", types)
+	}
+	z.mu.Lock()
+	pr.State.Dead = false
+	z.mu.Unlock()
+	svc.DialogueChoice(client, "npc-elder", 4)
+	if types := drainTypes(client); len(types) == 0 || types[0] != "error" || !contains(types, "quest_log") {
+		t.Fatalf("expected the failed turn-in to be reported along with the granted quest, got %v", types)
+	}
+	for i := 0; i < 100; i++ {
+		store.mu.Lock()
+		saved := store.quests[charID]
+		store.mu.Unlock()
+		if len(saved) == 2 {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("expected the quest granted before the failed action to be saved")
+}
+
+type recordingPublisher struct {
+	mu       sync.Mutex
+	subjects []string
+}
+
+func (p *recordingPublisher) Publish(_ context.Context, subject string, _ []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.subjects = append(p.subjects, subject)
+	return nil
+}
+
+func (p *recordingPublisher) Close() {}
+
+func chatTexts(c *Client) []string {
+	texts := make([]string, 0)
+	for len(c.Send) > 0 {
+		var msg map[string]any
+		if err := json.Unmarshal(<-c.Send, &msg); err == nil {
+			if msg["type"] == "chat" {
+				texts = append(texts, msg["channel"].(string)+":"+msg["text"].(string))
+			} else if msg["type"] == "error" {
+				texts = append(texts, "error")
+			}
+		}
+	}
+	return texts
+}
+
+func TestChatChannelsFilterAndRateLimit(t *testing.T) {
+	pub := &recordingPublisher{}
+	svc := NewService(zerolog.Nop(), pub, nil, "starter-zone", 10, "../../../data")
+	svc.SetChatFilter(NewBlocklistFilter([]string{"darn"}))
+
+	speaker := svc.RegisterClient(nil, uuid.New())
+	svc.Join(context.Background(), speaker, character.Character{ID: uuid.New(), Name: "Aria", ZoneID: "starter-zone", PosX: 3.5, PosY: 3.5})
+	near := svc.RegisterClient(nil, uuid.New())
+	svc.Join(context.Background(), near, character.Character{ID: uuid.New(), Name: "Bryn", ZoneID: "starter-zone", PosX: 5.5, PosY: 3.5})
+	far := svc.RegisterClient(nil, uuid.New())
+	svc.Join(context.Background(), far, character.Character{ID: uuid.New(), Name: "Cato", ZoneID: "starter-zone", PosX: 45.5, PosY: 45.5})
+	for _, c := range []*Client{speaker, near, far} {
+		drainTypes(c)
+	}
+
+	svc.Chat(speaker, ChatChannelSay, "hello", "")
+	svc.Chat(speaker, ChatChannelZone, "darn slimes", "")
+	svc.Chat(speaker, ChatChannelWhisper, "psst", "cato")
+	if got := fmt.Sprint(chatTexts(near)); got != "[say:hello zone:**** slimes]" {
+		t.Fatalf("unexpected chat for nearby player: %s", got)
+	}
+	if got := fmt.Sprint(chatTexts(far)); got != "[zone:**** slimes whisper:psst]" {
+		t.Fatalf("unexpected chat for distant player: %s", got)
+	}
+
+	svc.Chat(speaker, ChatChannelSay, string(make([]byte, chatMaxLength+1)), "")
+	svc.Chat(speaker, ChatChannelParty, "anyone?", "")
+	svc.Chat(speaker, ChatChannelSay, "one", "")
+	svc.Chat(speaker, ChatChannelSay, "two", "")
+	got := chatTexts(speaker)
+	if got[len(got)-1] != "error" || contains(got, "say:two") {
+		t.Fatalf("expected the burst limit to reject the last message, got %v", got)
+	}
+
+	pub.mu.Lock()
+	defer pub.mu.Unlock()
+	if !contains(pub.subjects, "chat.zone.starter-zone") || !contains(pub.subjects, "chat.whisper") {
+		t.Fatalf("expected chat to be published to NATS, got %v", pub.subjects)
+	}
+}
+
+func TestWhisperAndInviteRejectAmbiguousNames(t *testing.T) {
+	svc := NewService(zerolog.Nop(), nil, nil, "starter-zone", 10, "../../../data")
+	join := func(name string) (*Client, uuid.UUID) {
+		c := svc.RegisterClient(nil, uuid.New())
+		id := uuid.New()
+		svc.Join(context.Background(), c, character.Character{ID: id, Name: name, ZoneID: "starter-zone", PosX: 3.5, PosY: 3.5})
+		drainTypes(c)
+		return c, id
+	}
+	sender, _ := join("Aria")
+	first, firstID := join("Bryn")
+	second, _ := join("bryn")
+	for _, c := range []*Client{sender, first} {
+		drainTypes(c)
+	}
+
+	svc.Chat(sender, ChatChannelWhisper, "psst", "Bryn")
+	svc.PartyInvite(sender, "BRYN")
+	if got := fmt.Sprint(chatTexts(sender)); got != "[error error]" {
+		t.Fatalf("expected an ambiguous name to be rejected, got %s", got)
+	}
+	if got := append(drainTypes(first), drainTypes(second)...); len(got) != 0 {
+		t.Fatalf("expected nobody named Bryn to hear anything, got %v", got)
+	}
+
+	svc.Chat(sender, ChatChannelWhisper, "psst", firstID.String())
+	svc.PartyInvite(sender, firstID.String())
+	if got := fmt.Sprint(chatTexts(second)); got != "[]" {
+		t.Fatalf("expected the other Bryn to hear nothing, got %s", got)
+	}
+	if types := drainTypes(first); !contains(types, "chat") || !contains(types, "party_invite") {
+		t.Fatalf("expected the whisper and invite to reach the Bryn picked by ID, got %v", types)
+	}
+}
+
+func TestPartySharesKillXPAndFrames(t *testing.T) {
+	dir := t.TempDir()
+	writeTestMap(t, dir, "field", map[string]any{
+		"spawn_groups": []map[string]any{{"id": "mob-rat", "template": "rat", "x": 4, "y": 4}},
+	})
+	writeTestMobTemplates(t, dir, map[string]any{"id": "rat", "hp": 1, "xp_reward": 100})
+	svc := NewService(zerolog.Nop(), nil, nil, "field", 10, dir)
+	z := svc.zones["field"]
+
+	join := func(name string, x float64) (*Client, uuid.UUID) {
+		c := svc.RegisterClient(nil, uuid.New())
+		id := uuid.New()
+		svc.Join(context.Background(), c, character.Character{ID: id, Name: name, ZoneID: "field", PosX: x, PosY: 4.5})
+		drainTypes(c)
+		return c, id
+	}
+	leader, leaderID := join("Aria", 4.5)
+	member, memberID := join("Bryn", 6.5)
+	loner, lonerID := join("Cato", 7.5)
+
+	svc.PartyInvite(leader, "bryn")
+	if types := drainTypes(member); !contains(types, "party_invite") {
+		t.Fatalf("expected an invite, got %v", types)
+	}
+	svc.PartyAccept(member)
+	svc.PartyInvite(member, "cato")
+	if types := drainTypes(member); !contains(types, "party_update") || !contains(types, "error") {
+		t.Fatalf("expected to join the party and not to invite as a non-leader, got %v", types)
+	}
+	drainTypes(leader)
+
+	svc.Attack(leader, "mob-rat-1")
+	z.mu.RLock()
+	xp := []int{z.players[leaderID].State.Experience, z.players[memberID].State.Experience, z.players[lonerID].State.Experience}
+	z.mu.RUnlock()
+	if fmt.Sprint(xp) != "[50 50 0]" {
+		t.Fatalf("expected kill XP split between party members only, got %v", xp)
+	}
+
+	drainTypes(member)
+	for i := 0; i < partyFrameTicks; i++ {
+		svc.tickZone(z)
+	}
+	if types := drainTypes(member); !contains(types, "party_frames") {
+		t.Fatalf("expected party frames, got %v", types)
+	}
+	svc.Chat(leader, ChatChannelParty, "nice", "")
+	if got := chatTexts(member); !contains(got, "party:nice") || contains(chatTexts(loner), "party:nice") {
+		t.Fatalf("expected party chat to reach members only, got %v", got)
+	}
+
+	svc.PartyLeave(member)
+	if types := drainTypes(leader); !contains(types, "party_left") {
+		t.Fatalf("expected the party to disband when only the leader remains, got %v", types)
+	}
+	if ids := svc.partyMemberIDs(leaderID); ids != nil {
+		t.Fatalf("expected no party, got %v", ids)
+	}
+}
+
+func TestClassDrivesStatsAndAttackRange(t *testing.T) {
+	dir := t.TempDir()
+	writeTestClasses(t, dir)
+	writeTestMap(t, dir, "range", map[string]any{
+		"spawn_groups": []map[string]any{{"id": "mob-dummy", "template": "dummy", "x": 7.5, "y": 4.5, "patrol_radius": 0.1}},
+	})
+	writeTestMobTemplates(t, dir, map[string]any{"id": "dummy", "hp": 1000, "aggro_range": 0.1})
+	svc := NewService(zerolog.Nop(), nil, nil, "range", 10, dir)
+	z := svc.zones["range"]
+
+	warrior := svc.RegisterClient(nil, uuid.New())
+	warriorID := uuid.New()
+	svc.Join(context.Background(), warrior, character.Character{ID: warriorID, Name: "Aria", Class: "warrior", ZoneID: "range", PosX: 3.5, PosY: 4.5})
+	ranger := svc.RegisterClient(nil, uuid.New())
+	rangerID := uuid.New()
+	svc.Join(context.Background(), ranger, character.Character{ID: rangerID, Name: "Bryn", Class: "Ranger", ZoneID: "range", PosX: 3.5, PosY: 4.5})
+	legacy := svc.RegisterClient(nil, uuid.New())
+	legacyID := uuid.New()
+	svc.Join(context.Background(), legacy, character.Character{ID: legacyID, Name: "Cato", Class: "bard", ZoneID: "range", PosX: 3.5, PosY: 4.5})
+	for _, c := range []*Client{warrior, ranger, legacy} {
+		drainTypes(c)
+	}
+
+	z.mu.RLock()
+	w, r, l := z.players[warriorID].State, z.players[rangerID].State, z.players[legacyID].State
+	z.mu.RUnlock()
+	if w.Stats.Armor != 4 || w.MaxHP != 120 || r.Class != "ranger" || l.Class != character.DefaultClass {
+		t.Fatalf("expected class base stats and normalised classes, got %+v, %q, %q", w.Stats, r.Class, l.Class)
+	}
+
+	svc.Attack(warrior, "mob-dummy-1")
+	if types := drainTypes(warrior); !contains(types, "error") {
+		t.Fatalf("expected the warrior to be out of melee range, got %v", types)
+	}
+	svc.Attack(ranger, "mob-dummy-1")
+	if types := drainTypes(ranger); contains(types, "error") || !contains(types, "combat") {
+		t.Fatalf("expected the ranger to hit from range, got %v", types)
+	}
+
+	b, _ := json.Marshal(map[string]any{"classes": []map[string]any{{"id": "warrior", "resource": "energy", "attack_range": 1.3, "base_stats": map[string]any{"max_hp": 120}}}})
+	if err := os.WriteFile(filepath.Join(dir, "classes.json"), b, 0o644); err != nil {
+		t.Fatalf("write classes: %v", err)
+	}
+	if _, err := loadClasses(filepath.Join(dir, "classes.json")); err == nil {
+		t.Fatalf("expected a class catalog without the default class to fail loading")
+	}
+	if cls := NewService(zerolog.Nop(), nil, nil, "range", 10, dir).classes.OrDefault("warrior"); cls.ID != character.DefaultClass {
+		t.Fatalf("expected the built-in default class when the catalog fails, got %q", cls.ID)
+	}
+}
+
+func TestAbilitiesCooldownsCastTimeAndInterrupt(t *testing.T) {
+	dir := t.TempDir()
+	writeTestClasses(t, dir)
+	writeTestMap(t, dir, "arena", map[string]any{
+		"spawn_groups": []map[string]any{{"id": "mob-dummy", "template": "dummy", "x": 5, "y": 5, "count": 2, "radius": 1}},
+	})
+	writeTestMobTemplates(t, dir, map[string]any{"id": "dummy", "hp": 1000, "damage": 1, "aggro_range": 0.1})
+	b, _ := json.Marshal(map[string]any{"abilities": []map[string]any{
+		{"id": "frost-nova", "kind": "damage", "target": "self", "radius": 3, "power": 10, "cooldown_ticks": 50, "cost": 25},
+		{"id": "firebolt", "kind": "damage", "target": "enemy", "range": 8, "power": 30, "cast_ticks": 5, "cost": 10},
+	}})
+	if err := os.WriteFile(filepath.Join(dir, "abilities.json"), b, 0o644); err != nil {
+		t.Fatalf("write abilities: %v", err)
+	}
+	svc := NewService(zerolog.Nop(), nil, nil, "arena", 10, dir)
+	z := svc.zones["arena"]
+
+	client := svc.RegisterClient(nil, uuid.New())
+	charID := uuid.New()
+	svc.Join(context.Background(), client, character.Character{ID: charID, Name: "Aria", Class: "mage", ZoneID: "arena", PosX: 5.5, PosY: 5.5})
+	drainTypes(client)
+	mobHP := func() []int {
+		z.mu.RLock()
+		defer z.mu.RUnlock()
+		return []int{z.mobs["mob-dummy-1"].State.HP, z.mobs["mob-dummy-2"].State.HP}
+	}
+
+	svc.Cast(client, "frost-nova", "")
+	svc.Cast(client, "strike", "mob-dummy-1")
+	if hp := mobHP(); fmt.Sprint(hp) != "[990 990]" {
+		t.Fatalf("expected frost nova to hit both dummies, got %v", hp)
+	}
+	if types := drainTypes(client); !contains(types, "ability_hit") || types[len(types)-1] != "error" {
+		t.Fatalf("expected hits and a rejected ability from another class, got %v", types)
+	}
+	for i := 0; i < globalCooldownTicks; i++ {
+		svc.tickZone(z)
+	}
+	svc.Cast(client, "frost-nova", "")
+	if types := drainTypes(client); !contains(types, "error") {
+		t.Fatalf("expected frost nova to be on cooldown, got %v", types)
+	}
+
+	svc.Cast(client, "firebolt", "mob-dummy-1")
+	moveAndTick(svc, client, 1, 0)
+	if types := drainTypes(client); !contains(types, "ability_cast") || !contains(types, "ability_interrupted") {
+		t.Fatalf("expected the cast to start and be interrupted by movement, got %v", types)
+	}
+	for i := 0; i < globalCooldownTicks; i++ {
+		svc.tickZone(z)
+	}
+	svc.Cast(client, "firebolt", "mob-dummy-1")
+	drainTypes(client)
+	svc.Attack(client, "mob-dummy-2")
+	var msg struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(<-client.Send, &msg); err != nil || msg.Message != "already casting" {
+		t.Fatalf("expected attacking while casting to fail, got %+v", msg)
+	}
+	for i := 0; i < 5; i++ {
+		svc.tickZone(z)
+	}
+	if hp := mobHP(); hp[0] != 960 || hp[1] != 990 {
+		t.Fatalf("expected only the completed firebolt to land, got %v", hp)
+	}
+	z.mu.RLock()
+	mana := z.players[charID].State.Resource
+	z.mu.RUnlock()
+	if mana < 120-25-10 || mana >= 120 {
+		t.Fatalf("expected mana spent on completed casts only, then regenerating, got %d", mana)
+	}
+}
+
+func TestStatusEffectsStackTickAndExpire(t *testing.T) {
+	dir := t.TempDir()
+	writeTestClasses(t, dir)
+	writeTestMap(t, dir, "arena", map[string]any{
+		"spawn_groups": []map[string]any{{"id": "mob-dummy", "template": "dummy", "x": 7.5, "y": 7.5}},
+	})
+	writeTestMobTemplates(t, dir, map[string]any{"id": "dummy", "hp": 1000, "damage": 1, "aggro_range": 0.1})
+	writeTestItems(t, dir, map[string]any{"id": "tonic", "kind": "consumable", "max_stack": 5, "effects": []string{"rage", "shackle"}})
+	b, _ := json.Marshal(map[string]any{"effects": []map[string]any{
+		{"id": "venom", "kind": "dot", "duration_ticks": 30, "interval_ticks": 5, "amount": 5, "stacking": "stack", "max_stacks": 2},
+		{"id": "shackle", "kind": "root", "duration_ticks": 3},
+		{"id": "rage", "kind": "stat", "duration_ticks": 5, "stats": map[string]any{"attack_power": 10}},
+	}})
+	if err := os.WriteFile(filepath.Join(dir, "effects.json"), b, 0o644); err != nil {
+		t.Fatalf("write effects: %v", err)
+	}
+	b, _ = json.Marshal(map[string]any{"abilities": []map[string]any{
+		{"id": "firebolt", "kind": "damage", "target": "enemy", "range": 8, "power": 1, "effects": []string{"venom"}},
+	}})
+	if err := os.WriteFile(filepath.Join(dir, "abilities.json"), b, 0o644); err != nil {
+		t.Fatalf("write abilities: %v", err)
+	}
+	store := newFakeCharacterStore()
+	charID := uuid.New()
+	store.inventory[charID] = []character.InventorySlot{{Slot: 0, ItemID: "tonic", Quantity: 1}}
+	svc := NewService(zerolog.Nop(), nil, store, "arena", 10, dir)
+	z := svc.zones["arena"]
+
+	client := svc.RegisterClient(nil, uuid.New())
+	svc.Join(context.Background(), client, character.Character{ID: charID, Name: "Aria", Class: "mage", ZoneID: "arena", PosX: 2.5, PosY: 2.5})
+	drainTypes(client)
+	mob := func() domainworld.MobState {
+		z.mu.RLock()
+		defer z.mu.RUnlock()
+		return z.mobs["mob-dummy-1"].State
+	}
+	player := func() domainworld.PlayerState {
+		z.mu.RLock()
+		defer z.mu.RUnlock()
+		return z.players[charID].State
+	}
+
+	svc.Cast(client, "firebolt", "mob-dummy-1")
+	if m := mob(); len(m.Effects) != 1 || m.Effects[0].ID != "venom" || m.Effects[0].Stacks != 1 {
+		t.Fatalf("expected one stack of venom on the mob, got %+v", m.Effects)
+	}
+	for i := 0; i < globalCooldownTicks; i++ {
+		svc.tickZone(z)
+	}
+	if hp := mob().HP; hp != 989 {
+		t.Fatalf("expected two venom ticks after the hit, got hp %d", hp)
+	}
+	svc.Cast(client, "firebolt", "mob-dummy-1")
+	if m := mob(); m.Effects[0].Stacks != 2 || m.Effects[0].ExpiresTick != z.tick+30 {
+		t.Fatalf("expected venom to stack and refresh, got %+v", m.Effects)
+	}
+	for i := 0; i < 30; i++ {
+		svc.tickZone(z)
+	}
+	if m := mob(); m.HP != 928 || len(m.Effects) != 0 {
+		t.Fatalf("expected six double-stack ticks then expiry, got hp %d effects %+v", m.HP, m.Effects)
+	}
+	if types := drainTypes(client); !contains(types, "effect_tick") || !contains(types, "effect_removed") {
+		t.Fatalf("expected effect tick and removal events, got %v", types)
+	}
+
+	base := player()
+	svc.UseItem(client, 0)
+	if p := player(); p.Stats.AttackPower != base.Stats.AttackPower+10 || len(p.Effects) != 2 {
+		t.Fatalf("expected the tonic to apply rage and shackle, got %+v", p)
+	}
+	moveAndTick(svc, client, 1, 0)
+	if p := player(); p.X != base.X {
+		t.Fatalf("expected a rooted player to stay put, moved to %v", p.X)
+	}
+	for i := 0; i < 5; i++ {
+		svc.tickZone(z)
+	}
+	moveAndTick(svc, client, 1, 0)
+	if p := player(); p.X <= base.X || p.Stats.AttackPower != base.Stats.AttackPower || len(p.Effects) != 0 {
+		t.Fatalf("expected effects to wear off, got %+v", p)
+	}
+}
+
+func TestMobChoosesAttacksByRangeCooldownAndHealth(t *testing.T) {
+	dir := t.TempDir()
+	writeTestMap(t, dir, "den", map[string]any{
+		"spawn_groups": []map[string]any{{"id": "mob-brute", "template": "brute", "x": 7.5, "y": 7.5}},
+	})
+	writeTestMobTemplates(t, dir, map[string]any{
+		"id": "brute", "hp": 100, "damage": 5, "aggro_range": 8, "attack_cooldown_ticks": 5,
+		"attacks": []map[string]any{
+			{"id": "rage", "kind": "enrage", "hp_below": 0.5, "effects": []string{"fury"}},
+			{"id": "slam", "kind": "slam", "radius": 2, "damage": 7, "cooldown_ticks": 20, "hp_below": 0.5},
+			{"id": "spit", "kind": "ranged", "range": 6, "damage": 3, "cooldown_ticks": 100},
+			{"id": "claw", "kind": "melee"},
+		},
+	})
+	b, _ := json.Marshal(map[string]any{"effects": []map[string]any{
+		{"id": "fury", "kind": "stat", "duration_ticks": 1000, "stats": map[string]any{"attack_power": 10}},
+	}})
+	if err := os.WriteFile(filepath.Join(dir, "effects.json"), b, 0o644); err != nil {
+		t.Fatalf("write effects: %v", err)
+	}
+	svc := NewService(zerolog.Nop(), nil, nil, "den", 10, dir)
+	z := svc.zones["den"]
+
+	client := svc.RegisterClient(nil, uuid.New())
+	svc.Join(context.Background(), client, character.Character{ID: uuid.New(), Name: "Aria", ZoneID: "den", PosX: 2.5, PosY: 7.5})
+	drainTypes(client)
+	attacks := func() []string {
+		var ids []string
+		for {
+			select {
+			case msg := <-client.Send:
+				var p struct {
+					Type     string `json:"type"`
+					AttackID string `json:"attack_id"`
+				}
+				if err := json.Unmarshal(msg, &p); err == nil && (p.Type == "combat" || p.Type == "mob_enraged") {
+					ids = append(ids, p.AttackID)
+				}
+			default:
+				return ids
+			}
+		}
+	}
+
+	var used []string
+	for i := 0; i < 60 && !contains(used, "claw"); i++ {
+		svc.tickZone(z)
+		used = append(used, attacks()...)
+	}
+	if len(used) < 2 || used[0] != "spit" || used[len(used)-1] != "claw" || contains(used, "slam") || contains(used, "rage") {
+		t.Fatalf("expected a ranged opener, then melee once in reach, got %v", used)
+	}
+
+	z.mu.Lock()
+	z.mobs["mob-brute-1"].State.HP = 40
+	z.mu.Unlock()
+	svc.tickZone(z)
+	svc.tickZone(z)
+	if used := attacks(); len(used) != 2 || used[0] != "rage" || used[1] != "slam" {
+		t.Fatalf("expected the mob to enrage then slam once hurt, got %v", used)
+	}
+	z.mu.RLock()
+	mob := z.mobs["mob-brute-1"]
+	effects, bonus := mob.State.Effects, mob.Mods.Stats.AttackPower
+	z.mu.RUnlock()
+	if len(effects) != 1 || bonus != 10 {
+		t.Fatalf("expected fury on the mob, got %+v", effects)
+	}
+	for i := 0; i < 30; i++ {
+		svc.tickZone(z)
+	}
+	if used := attacks(); contains(used, "rage") || !contains(used, "slam") {
+		t.Fatalf("expected no second enrage and slam off cooldown again, got %v", used)
+	}
+}
+
+func TestRegenerationPausesInCombatAndRestingSpeedsItUp(t *testing.T) {
+	dir := t.TempDir()
+	writeTestMap(t, dir, "camp", map[string]any{
+		"spawn_groups": []map[string]any{{"id": "mob-dummy", "template": "dummy", "x": 7.5, "y": 7.5}},
+	})
+	writeTestMobTemplates(t, dir, map[string]any{"id": "dummy", "hp": 1000, "damage": 10, "aggro_range": 0.1})
+	writeTestItems(t, dir, map[string]any{"id": "bread", "kind": "consumable", "max_stack": 5, "heal": 10, "food": true})
+	store := newFakeCharacterStore()
+	charID := uuid.New()
+	store.inventory[charID] = []character.InventorySlot{{Slot: 0, ItemID: "bread", Quantity: 2}}
+	svc := NewService(zerolog.Nop(), nil, store, "camp", 10, dir)
+	z := svc.zones["camp"]
+
+	client := svc.RegisterClient(nil, uuid.New())
+	svc.Join(context.Background(), client, character.Character{ID: charID, Name: "Aria", ZoneID: "camp", PosX: 2.5, PosY: 2.5, Progress: character.Progress{HP: 20, MaxHP: 100}})
+	drainTypes(client)
+	player := func() domainworld.PlayerState {
+		z.mu.RLock()
+		defer z.mu.RUnlock()
+		return z.players[charID].State
+	}
+	ticks := func(n int) {
+		for i := 0; i < n; i++ {
+			svc.tickZone(z)
+		}
+	}
+
+	ticks(10)
+	if hp := player().HP; hp != 22 {
+		t.Fatalf("expected 2 hp regenerated in a second, got %d", hp)
+	}
+	if types := drainTypes(client); !contains(types, "player_update") {
+		t.Fatalf("expected regeneration to push a player_update, got %v", types)
+	}
+
+	z.mu.Lock()
+	mob := z.mobs["mob-dummy-1"]
+	z.applyMobAttackLocked(mob, mob.Template.Attacks[0], z.players[charID])
+	z.mu.Unlock()
+	svc.Rest(client)
+	if types := drainTypes(client); !contains(types, "error") {
+		t.Fatalf("expected resting to be refused in combat, got %v", types)
+	}
+	ticks(combatLingerTicks - 10)
+	if hp := player().HP; hp != 12 {
+		t.Fatalf("expected no regeneration in combat, got %d", hp)
+	}
+	ticks(20)
+	if hp := player().HP; hp <= 12 {
+		t.Fatalf("expected regeneration to resume after combat, got %d", hp)
+	}
+
+	before := player().HP
+	svc.Rest(client)
+	ticks(10)
+	if p := player(); !p.Resting || p.HP != before+2*restRegenMultiplier {
+		t.Fatalf("expected resting to triple regeneration, got resting=%v hp %d from %d", p.Resting, p.HP, before)
+	}
+	before = player().HP
+	svc.UseItem(client, 0)
+	ticks(10)
+	if hp := player().HP; hp != before+2*restRegenMultiplier+10 {
+		t.Fatalf("expected bread to add 10 hp a second while resting, got %d from %d", hp, before)
+	}
+
+	svc.Move(client, 1, 0, 0)
+	before = player().HP
+	ticks(10)
+	if p := player(); p.Resting || p.HP != before+2 {
+		t.Fatalf("expected moving to end the rest and the meal, got resting=%v hp %d from %d", p.Resting, p.HP, before)
+	}
+}
+
+func TestDeathReleaseCorpseRunResurrectionAndPenalties(t *testing.T) {
+	dir := t.TempDir()
+	writeTestMap(t, dir, "yard", map[string]any{
+		"graveyards": []map[string]any{{"id": "gy-east", "x": 7.5, "y": 7.5}, {"id": "gy-west", "x": 2.5, "y": 7.5}},
+		"npcs":       []map[string]any{{"id": "smith", "name": "Smith", "x": 4, "y": 4, "interactions": []string{"repair"}}},
+	})
+	writeTestItems(t, dir, map[string]any{"id": "plate", "name": "Plate", "kind": "armor", "slot": "chest", "value": 40, "durability": 20, "stats": map[string]any{"armor": 5}})
+	store := newFakeCharacterStore()
+	ariaID, branID := uuid.New(), uuid.New()
+	store.equipment[ariaID] = []character.EquippedItem{{Slot: "chest", ItemID: "plate"}}
+	svc := NewService(zerolog.Nop(), nil, store, "yard", 10, dir)
+	z := svc.zones["yard"]
+
+	aria := svc.RegisterClient(nil, uuid.New())
+	svc.Join(context.Background(), aria, character.Character{ID: ariaID, Name: "Aria", ZoneID: "yard", PosX: 6.5, PosY: 2.5, Progress: character.Progress{Gold: 100}})
+	bran := svc.RegisterClient(nil, uuid.New())
+	svc.Join(context.Background(), bran, character.Character{ID: branID, Name: "Bran", ZoneID: "yard", PosX: 6.5, PosY: 4.5})
+	drainTypes(aria)
+	drainTypes(bran)
+	player := func() domainworld.PlayerState {
+		z.mu.RLock()
+		defer z.mu.RUnlock()
+		return z.players[ariaID].State
+	}
+	kill := func() {
+		z.mu.Lock()
+		events := z.killPlayerLocked(z.players[ariaID])
+		z.mu.Unlock()
+		z.dispatch(events)
+	}
+	place := func(x, y float64) {
+		z.mu.Lock()
+		z.relocateLocked(z.players[ariaID], x, y)
+		z.mu.Unlock()
+	}
+
+	kill()
+	if p := player(); !p.Dead || p.HP != 0 || p.Corpse == nil || *p.Corpse != (domainworld.SpawnPoint{X: 6.5, Y: 2.5}) {
+		t.Fatalf("expected aria dead at her corpse, got %+v", p)
+	}
+	if types := drainTypes(bran); !contains(types, "player_died") {
+		t.Fatalf("expected bran to see player_died, got %v", types)
+	}
+	drainTypes(aria)
+	svc.Move(aria, 1, 0, 0)
+	svc.Attack(aria, "nothing")
+	if types := drainTypes(aria); len(types) < 2 || types[0] != "error" || types[1] != "error" {
+		t.Fatalf("expected the dead to be unable to move or attack, got %v", types)
+	}
+
+	svc.Release(aria)
+	if p := player(); !p.Ghost || p.X != 7.5 || p.Y != 7.5 || p.XPDebt != 0 {
+		t.Fatalf("expected a ghost at the nearest graveyard without penalty, got %+v", p)
+	}
+	svc.Reclaim(aria)
+	if types := drainTypes(aria); !contains(types, "error") {
+		t.Fatalf("expected reclaiming from the graveyard to be too far, got %v", types)
+	}
+	svc.Move(aria, 0, -1, 0)
+	svc.tickZone(z)
+	if p := player(); p.Y >= 7.5 {
+		t.Fatalf("expected the ghost to walk, got %+v", p)
+	}
+	place(6.5, 4.5)
+	svc.Reclaim(aria)
+	if p := player(); p.Dead || p.HP != p.MaxHP/2 || p.X != 6.5 || p.Y != 2.5 || p.Wear != nil {
+		t.Fatalf("expected a free revive at the corpse with half hp, got %+v", p)
+	}
+
+	kill()
+	svc.AcceptResurrect(aria)
+	if types := drainTypes(aria); !contains(types, "error") {
+		t.Fatalf("expected accepting without an offer to fail, got %v", types)
+	}
+	svc.Resurrect(bran, ariaID.String())
+	if types := drainTypes(aria); !contains(types, "resurrect_offer") {
+		t.Fatalf("expected aria to be offered a resurrection, got %v", types)
+	}
+	svc.AcceptResurrect(aria)
+	if p := player(); p.Dead || p.HP != p.MaxHP*35/100 || p.XPDebt != 0 {
+		t.Fatalf("expected resurrection without penalty, got %+v", p)
+	}
+	if types := drainTypes(bran); !contains(types, "player_respawned") {
+		t.Fatalf("expected bran to see aria respawn, got %v", types)
+	}
+
+	kill()
+	svc.Release(aria)
+	svc.Release(aria)
+	p := player()
+	if p.Dead || p.X != 7.5 || p.Y != 7.5 || p.XPDebt != 10 || p.Wear[domainworld.EquipSlotChest] != 2 {
+		t.Fatalf("expected a graveyard respawn with xp debt and wear, got %+v", p)
+	}
+	z.mu.Lock()
+	svc.grantXPLocked(z.players[ariaID], 30)
+	z.mu.Unlock()
+	if p := player(); p.XPDebt != 0 || p.Experience != 20 {
+		t.Fatalf("expected half of earned xp to pay off the debt, got debt %d xp %d", p.XPDebt, p.Experience)
+	}
+
+	svc.Unequip(aria, domainworld.EquipSlotChest)
+	z.mu.RLock()
+	slots := z.players[ariaID].Inventory.Slots()
+	z.mu.RUnlock()
+	if p := player(); p.Equipment[domainworld.EquipSlotChest] != "" || p.Wear != nil || len(slots) != 1 || slots[0].Wear != 2 {
+		t.Fatalf("expected worn gear to come off with its wear, got %v %v", p.Equipment, slots)
+	}
+	svc.Equip(aria, slots[0].Slot)
+	if p := player(); p.Equipment[domainworld.EquipSlotChest] != "plate" || p.Wear[domainworld.EquipSlotChest] != 2 {
+		t.Fatalf("expected the plate back on with its wear, got %v %v", p.Equipment, p.Wear)
+	}
+	gold := player().Gold
+	svc.Interact(aria, "smith", "repair", InteractArgs{})
+	if p := player(); p.Wear != nil || p.Gold != gold-4 {
+		t.Fatalf("expected repair to cost 4 gold, got wear %v gold %d from %d", p.Wear, p.Gold, gold)
+	}
+
+	kill()
+	svc.UnregisterClient(context.Background(), aria)
+	store.mu.Lock()
+	progress, equipped := store.progress[ariaID], store.equipment[ariaID]
+	store.mu.Unlock()
+	if progress.HP <= 0 || progress.XPDebt != 10 || len(equipped) != 1 || equipped[0].Wear != 2 {
+		t.Fatalf("expected logging out dead to release with the penalty, got %+v %+v", progress, equipped)
+	}
+}
+
+func TestStopReleasesTheDeadBeforeSaving(t *testing.T) {
+	dir := t.TempDir()
+	writeTestMap(t, dir, "yard", map[string]any{
+		"graveyards": []map[string]any{{"id": "gy", "x": 7.5, "y": 7.5}},
+	})
+	store := newFakeCharacterStore()
+	svc := NewService(zerolog.Nop(), nil, store, "yard", 10, dir)
+	z := svc.zones["yard"]
+	ariaID := uuid.New()
+	aria := svc.RegisterClient(nil, uuid.New())
+	svc.Join(context.Background(), aria, character.Character{ID: ariaID, Name: "Aria", ZoneID: "yard", PosX: 2.5, PosY: 2.5})
+	svc.Start()
+
+	z.mu.Lock()
+	z.killPlayerLocked(z.players[ariaID])
+	z.mu.Unlock()
+	svc.Stop()
+
+	store.mu.Lock()
+	progress := store.progress[ariaID]
+	store.mu.Unlock()
+	if progress.HP <= 0 || progress.XPDebt != 10 {
+		t.Fatalf("expected shutting down dead to release with the penalty, got %+v", progress)
+	}
+}
+
+func TestPositionSavesAreThrottledWhileMoving(t *testing.T) {
+	dir := t.TempDir()
+	writeTestMap(t, dir, "road", nil)
+	store := newFakeCharacterStore()
+	svc := NewService(zerolog.Nop(), nil, store, "road", 10, dir)
+	client := svc.RegisterClient(nil, uuid.New())
+	svc.Join(context.Background(), client, character.Character{ID: uuid.New(), Name: "Aria", ZoneID: "road", PosX: 4.5, PosY: 4.5})
+
+	saves := func(want int) int {
+		var n int
+		for i := 0; i < 100; i++ {
+			store.mu.Lock()
+			n = len(store.zones)
+			store.mu.Unlock()
+			if n >= want {
+				break
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		return n
+	}
+	dx := 1.0
+	for i := 0; i < positionSaveTicks; i++ {
+		moveAndTick(svc, client, dx, 0)
+		dx = -dx
+	}
+	if n := saves(2); n != 1 {
+		t.Fatalf("expected one position save while moving for %d ticks, got %d", positionSaveTicks, n)
+	}
+	moveAndTick(svc, client, dx, 0)
+	if n := saves(2); n != 2 {
+		t.Fatalf("expected another save once the interval passed, got %d", n)
+	}
+}
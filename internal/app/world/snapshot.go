@@ -0,0 +1,105 @@
+package world
+
+import (
+	"math"
+	"slices"
+
+	domainworld "mmorp-server/internal/domain/world"
+)
+
+const (
+	snapshotKeyframeTicks = 50
+	snapshotPositionEps   = 1e-3
+)
+
+type mobDelta struct {
+	ID      string                      `json:"id"`
+	X       *float64                    `json:"x,omitempty"`
+	Y       *float64                    `json:"y,omitempty"`
+	HP      *int                        `json:"hp,omitempty"`
+	MaxHP   *int                        `json:"max_hp,omitempty"`
+	Alive   *bool                       `json:"alive,omitempty"`
+	AIState *domainworld.MobAIState     `json:"ai_state,omitempty"`
+	Effects *[]domainworld.ActiveEffect `json:"effects,omitempty"`
+}
+
+func fullMobDelta(m domainworld.MobState) mobDelta {
+	return mobDelta{ID: m.ID, X: &m.X, Y: &m.Y, HP: &m.HP, MaxHP: &m.MaxHP, Alive: &m.Alive, AIState: &m.AIState, Effects: &m.Effects}
+}
+
+func diffMob(base, cur domainworld.MobState) (mobDelta, bool) {
+	d := mobDelta{ID: cur.ID}
+	changed := false
+	if math.Abs(base.X-cur.X) > snapshotPositionEps || math.Abs(base.Y-cur.Y) > snapshotPositionEps {
+		d.X, d.Y = &cur.X, &cur.Y
+		changed = true
+	}
+	if base.HP != cur.HP {
+		d.HP = &cur.HP
+		changed = true
+	}
+	if base.MaxHP != cur.MaxHP {
+		d.MaxHP = &cur.MaxHP
+		changed = true
+	}
+	if base.Alive != cur.Alive {
+		d.Alive = &cur.Alive
+		changed = true
+	}
+	if base.AIState != cur.AIState {
+		d.AIState = &cur.AIState
+		changed = true
+	}
+	if !slices.EqualFunc(base.Effects, cur.Effects, sameEffect) {
+		d.Effects = &cur.Effects
+		changed = true
+	}
+	return d, changed
+}
+
+// sameEffect ignores the internal pulse schedule, which clients never see.
+func sameEffect(a, b domainworld.ActiveEffect) bool {
+	return a.ID == b.ID && a.Stacks == b.Stacks && a.ExpiresTick == b.ExpiresTick && a.SourceID == b.SourceID
+}
+
+// mobSnapshotLocked builds the per-tick mob message for one player: a full
+// keyframe when due, otherwise only the fields that changed since the last
+// snapshot the player was sent. It returns nil when there is nothing to send.
+func (z *zone) mobSnapshotLocked(pr *playerRuntime) map[string]any {
+	if pr.MobBaseline == nil || z.tick-pr.LastKeyframe >= snapshotKeyframeTicks {
+		mobs := make([]domainworld.MobState, 0, len(pr.VisibleMobs))
+		baseline := make(map[string]domainworld.MobState, len(pr.VisibleMobs))
+		for id := range pr.VisibleMobs {
+			state := z.mobs[id].State
+			mobs = append(mobs, state)
+			baseline[id] = state
+		}
+		pr.MobBaseline = baseline
+		pr.LastKeyframe = z.tick
+		return map[string]any{"type": "mob_update", "tick": z.tick, "keyframe": true, "mobs": mobs}
+	}
+
+	for id := range pr.MobBaseline {
+		if _, visible := pr.VisibleMobs[id]; !visible {
+			delete(pr.MobBaseline, id)
+		}
+	}
+	deltas := make([]mobDelta, 0)
+	for id := range pr.VisibleMobs {
+		cur := z.mobs[id].State
+		base, known := pr.MobBaseline[id]
+		if !known {
+			deltas = append(deltas, fullMobDelta(cur))
+			pr.MobBaseline[id] = cur
+			continue
+		}
+		if d, changed := diffMob(base, cur); changed {
+			deltas = append(deltas, d)
+			pr.MobBaseline[id] = cur
+		}
+	}
+	if len(deltas) == 0 {
+		return nil
+	}
+	return map[string]any{"type": "mob_delta", "tick": z.tick, "mobs": deltas}
+}
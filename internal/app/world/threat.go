@@ -0,0 +1,82 @@
+package world
+
+import (
+	"github.com/google/uuid"
+)
+
+const (
+	mobProximityThreat   = 1.0
+	mobThreatDecay       = 0.99
+	mobThreatFloor       = 0.1
+	mobThreatSwitchRatio = 1.1
+	// Healing someone a mob is fighting draws half as much threat as the
+	// same amount of damage would.
+	healThreatRatio = 0.5
+)
+
+func (mob *mobRuntime) addThreat(playerID uuid.UUID, amount float64) {
+	if amount <= 0 {
+		return
+	}
+	if mob.Threat == nil {
+		mob.Threat = make(map[uuid.UUID]float64)
+	}
+	mob.Threat[playerID] += amount
+}
+
+// addHealThreatLocked gives the healer threat on every mob that has the
+// healed player on its table.
+func (z *zone) addHealThreatLocked(healerID, targetID uuid.UUID, healed int) {
+	for _, mob := range z.mobs {
+		if _, ok := mob.Threat[targetID]; ok && mob.State.Alive {
+			mob.addThreat(healerID, float64(healed)*healThreatRatio)
+		}
+	}
+}
+
+func (mob *mobRuntime) wipeThreat() {
+	mob.Threat = nil
+}
+
+// updateThreatLocked decays every entry, drops players who can no longer be
+// fought, and keeps anyone standing in aggro range on the table.
+func (z *zone) updateThreatLocked(mob *mobRuntime) {
+	for id, threat := range mob.Threat {
+		p, ok := z.players[id]
+		threat *= mobThreatDecay
+		if !ok || p.State.HP <= 0 || !mob.inLeash(p.State.X, p.State.Y) || threat < mobThreatFloor {
+			delete(mob.Threat, id)
+			continue
+		}
+		mob.Threat[id] = threat
+	}
+	for _, p := range z.playersNearLocked(mob.State.X, mob.State.Y, mob.Template.AggroRange) {
+		if p.State.HP <= 0 {
+			continue
+		}
+		if mob.Threat[p.State.ID] < mobProximityThreat {
+			mob.addThreat(p.State.ID, mobProximityThreat-mob.Threat[p.State.ID])
+		}
+	}
+}
+
+// selectTargetLocked picks the player with the most threat. The current
+// target keeps aggro until someone else exceeds it by mobThreatSwitchRatio.
+func (z *zone) selectTargetLocked(mob *mobRuntime) *playerRuntime {
+	var best *playerRuntime
+	bestThreat := 0.0
+	for id, threat := range mob.Threat {
+		if threat > bestThreat {
+			best, bestThreat = z.players[id], threat
+		}
+	}
+	if best == nil {
+		mob.TargetID = uuid.Nil
+		return nil
+	}
+	if current, ok := mob.Threat[mob.TargetID]; ok && best.State.ID != mob.TargetID && bestThreat < current*mobThreatSwitchRatio {
+		return z.players[mob.TargetID]
+	}
+	mob.TargetID = best.State.ID
+	return best
+}
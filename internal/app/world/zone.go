@@ -0,0 +1,282 @@
+package world
+
+import (
+	"encoding/json"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+
+	"mmorp-server/internal/domain/character"
+	domainworld "mmorp-server/internal/domain/world"
+)
+
+type playerRuntime struct {
+	State          domainworld.PlayerState
+	Class          character.Class
+	Client         *Client
+	VisiblePlayers map[uuid.UUID]struct{}
+	VisibleMobs    map[string]struct{}
+	MobBaseline    map[string]domainworld.MobState
+	LastKeyframe   uint64
+	PendingMove    *moveInput
+	InputSeq       uint64
+	LastMoveSeq    uint64
+	Inventory      *inventory
+	Quests         map[string]*character.QuestProgress
+	Dialogue       *dialogueSession
+	Cooldowns      map[string]uint64
+	GCDUntil       uint64
+	Cast           *castState
+	Mods           effectModifiers
+	LastCombatTick uint64
+	Meal           *mealState
+	Resurrect      *resurrectOffer
+	// NextPositionSave is the first tick a move may persist the position
+	// again.
+	NextPositionSave uint64
+}
+
+type mobRuntime struct {
+	State             domainworld.MobState
+	Template          domainworld.MobTemplate
+	SpawnX            float64
+	SpawnY            float64
+	Cooldowns         map[string]uint64
+	Enraged           bool
+	RespawnCounter    int
+	WanderDX          float64
+	WanderDY          float64
+	WanderTicksRemain int
+	IdleTicksRemain   int
+	EvadeTicks        int
+	TargetID          uuid.UUID
+	Threat            map[uuid.UUID]float64
+	Path              []pathPoint
+	PathGoal          pathPoint
+	PathAge           int
+	Mods              effectModifiers
+}
+
+type zone struct {
+	id       string
+	logger   zerolog.Logger
+	worldMap domainworld.TileMap
+	npcs     []domainworld.NPC
+	effects  map[string]domainworld.StatusEffect
+
+	mu         sync.RWMutex
+	players    map[uuid.UUID]*playerRuntime
+	mobs       map[string]*mobRuntime
+	playerGrid *spatialGrid[uuid.UUID]
+	mobGrid    *spatialGrid[string]
+	loot       map[string]*groundLoot
+	lootSeq    uint64
+	tick       uint64
+	rand       *rand.Rand
+}
+
+type zoneEvent struct {
+	X       float64
+	Y       float64
+	Global  bool
+	Payload any
+}
+
+func newZone(logger zerolog.Logger, data zoneData, effects map[string]domainworld.StatusEffect) *zone {
+	mobState := make(map[string]*mobRuntime, len(data.Mobs))
+	mobGrid := newSpatialGrid[string](interestCellSize)
+	for i := range data.Mobs {
+		m := data.Mobs[i].State
+		mobState[m.ID] = &mobRuntime{
+			State:    m,
+			Template: data.Mobs[i].Template,
+			SpawnX:   m.X,
+			SpawnY:   m.Y,
+		}
+		mobGrid.Upsert(m.ID, m.X, m.Y)
+	}
+	return &zone{
+		id:         data.ID,
+		logger:     logger.With().Str("zone_id", data.ID).Logger(),
+		worldMap:   data.Map,
+		npcs:       data.NPCs,
+		effects:    effects,
+		players:    make(map[uuid.UUID]*playerRuntime),
+		mobs:       mobState,
+		playerGrid: newSpatialGrid[uuid.UUID](interestCellSize),
+		mobGrid:    mobGrid,
+		loot:       make(map[string]*groundLoot),
+		rand:       rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+func (z *zone) dispatch(events []zoneEvent) {
+	for _, evt := range events {
+		if evt.Global {
+			z.broadcast(uuid.Nil, evt.Payload)
+			continue
+		}
+		z.broadcastNear(uuid.Nil, evt.X, evt.Y, evt.Payload)
+	}
+}
+
+func (z *zone) playerStatesLocked() []domainworld.PlayerState {
+	players := make([]domainworld.PlayerState, 0, len(z.players))
+	for _, p := range z.players {
+		players = append(players, p.State)
+	}
+	return players
+}
+
+func (z *zone) mobStatesLocked() []domainworld.MobState {
+	mobs := make([]domainworld.MobState, 0, len(z.mobs))
+	for _, m := range z.mobs {
+		mobs = append(mobs, m.State)
+	}
+	return mobs
+}
+
+func (z *zone) welcomePayloadLocked(msgType string, pr *playerRuntime) map[string]any {
+	players := make([]domainworld.PlayerState, 0, len(pr.VisiblePlayers)+1)
+	players = append(players, pr.State)
+	for id := range pr.VisiblePlayers {
+		players = append(players, z.players[id].State)
+	}
+	mobs := make([]domainworld.MobState, 0, len(pr.VisibleMobs))
+	for id := range pr.VisibleMobs {
+		mobs = append(mobs, z.mobs[id].State)
+	}
+	return map[string]any{
+		"type":      msgType,
+		"selfId":    pr.State.ID,
+		"character": pr.State,
+		"zone_id":   z.id,
+		"inventory": pr.Inventory.payload(),
+		"world": map[string]any{
+			"zone_id": z.id,
+			"map":     z.worldMap,
+			"players": players,
+			"mobs":    mobs,
+			"npcs":    z.npcs,
+			"loot":    z.groundLootLocked(),
+		},
+	}
+}
+
+func (z *zone) state() domainworld.WorldState {
+	z.mu.RLock()
+	defer z.mu.RUnlock()
+	return domainworld.WorldState{
+		Tick:    z.tick,
+		ZoneID:  z.id,
+		Map:     z.worldMap,
+		Players: z.playerStatesLocked(),
+		NPCs:    append([]domainworld.NPC(nil), z.npcs...),
+		Mobs:    z.mobStatesLocked(),
+		Loot:    z.groundLootLocked(),
+	}
+}
+
+func (z *zone) isWalkable(x, y float64) bool {
+	return z.isWalkableWithRadius(x, y, 0)
+}
+
+func (z *zone) isWalkableWithRadius(x, y, radius float64) bool {
+	checks := [][2]float64{{x, y}, {x - radius, y}, {x + radius, y}, {x, y - radius}, {x, y + radius}}
+	for _, c := range checks {
+		t := z.tileAt(c[0], c[1])
+		if t == domainworld.TileWall || t == domainworld.TileWater {
+			return false
+		}
+	}
+	return true
+}
+
+func (z *zone) tileAt(x, y float64) domainworld.TileType {
+	if x < 0 || y < 0 {
+		return domainworld.TileWall
+	}
+	tx := int(math.Floor(x))
+	ty := int(math.Floor(y))
+	if tx < 0 || tx >= z.worldMap.Width || ty < 0 || ty >= z.worldMap.Height {
+		return domainworld.TileWall
+	}
+	return z.worldMap.Tiles[ty][tx]
+}
+
+func (z *zone) portalAt(x, y float64) (domainworld.Portal, bool) {
+	for _, p := range z.worldMap.Portals {
+		if p.Contains(x, y) {
+			return p, true
+		}
+	}
+	return domainworld.Portal{}, false
+}
+
+func (z *zone) findNPC(npcID string) *domainworld.NPC {
+	for i := range z.npcs {
+		if z.npcs[i].ID == npcID {
+			return &z.npcs[i]
+		}
+	}
+	return nil
+}
+
+func (z *zone) broadcastNear(skipPlayerID uuid.UUID, x, y float64, payload any) {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		z.logger.Error().Err(err).Msg("marshal ws payload failed")
+		return
+	}
+
+	z.mu.RLock()
+	defer z.mu.RUnlock()
+	for _, p := range z.playersNearLocked(x, y, viewRadius) {
+		if skipPlayerID != uuid.Nil && p.State.ID == skipPlayerID {
+			continue
+		}
+		nonBlockingSend(p.Client.Send, b)
+	}
+}
+
+func (z *zone) sendLocked(recipients []*playerRuntime, payload any) {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		z.logger.Error().Err(err).Msg("marshal ws payload failed")
+		return
+	}
+	for _, p := range recipients {
+		nonBlockingSend(p.Client.Send, b)
+	}
+}
+
+// sendToPlayer delivers payload to a player only while they are still in the
+// zone; a player who has left may already have had their channel closed.
+func (z *zone) sendToPlayer(id uuid.UUID, payload any) {
+	z.mu.RLock()
+	defer z.mu.RUnlock()
+	if pr, ok := z.players[id]; ok {
+		nonBlockingSendJSON(pr.Client.Send, payload)
+	}
+}
+
+func (z *zone) broadcast(skipPlayerID uuid.UUID, payload any) {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		z.logger.Error().Err(err).Msg("marshal ws payload failed")
+		return
+	}
+
+	z.mu.RLock()
+	defer z.mu.RUnlock()
+	for id, p := range z.players {
+		if skipPlayerID != uuid.Nil && id == skipPlayerID {
+			continue
+		}
+		nonBlockingSend(p.Client.Send, b)
+	}
+}
@@ -6,13 +6,77 @@ import (
 	"github.com/google/uuid"
 )
 
+const (
+	DefaultLevel = 1
+	DefaultMaxHP = 100
+
+	InventoryCapacity = 20
+
+	QuestStatusActive    = "active"
+	QuestStatusCompleted = "completed"
+)
+
 type Character struct {
-	ID        uuid.UUID `json:"id"`
-	UserID    uuid.UUID `json:"user_id"`
-	Name      string    `json:"name"`
-	Class     string    `json:"class"`
-	ZoneID    string    `json:"zone_id"`
-	PosX      float64   `json:"pos_x"`
-	PosY      float64   `json:"pos_y"`
+	ID     uuid.UUID `json:"id"`
+	UserID uuid.UUID `json:"user_id"`
+	Name   string    `json:"name"`
+	Class  string    `json:"class"`
+	ZoneID string    `json:"zone_id"`
+	PosX   float64   `json:"pos_x"`
+	PosY   float64   `json:"pos_y"`
+	Progress
 	CreatedAt time.Time `json:"created_at"`
 }
+
+type Progress struct {
+	Level      int `json:"level"`
+	Experience int `json:"experience"`
+	Gold       int `json:"gold"`
+	HP         int `json:"hp"`
+	MaxHP      int `json:"max_hp"`
+	XPDebt     int `json:"xp_debt"`
+}
+
+func (p Progress) WithDefaults() Progress {
+	if p.Level <= 0 {
+		p.Level = DefaultLevel
+	}
+	if p.MaxHP <= 0 {
+		p.MaxHP = DefaultMaxHP
+	}
+	if p.HP <= 0 || p.HP > p.MaxHP {
+		p.HP = p.MaxHP
+	}
+	if p.Gold < 0 {
+		p.Gold = 0
+	}
+	if p.XPDebt < 0 {
+		p.XPDebt = 0
+	}
+	return p
+}
+
+// InventorySlot is a stack in the bags. Wear is carried over from when the
+// item was last equipped, so taking worn gear off does not mend it.
+type InventorySlot struct {
+	Slot     int    `json:"slot"`
+	ItemID   string `json:"item_id"`
+	Quantity int    `json:"quantity"`
+	Wear     int    `json:"wear,omitempty"`
+}
+
+// EquippedItem is an item worn in a slot. Wear is the durability it has
+// lost.
+type EquippedItem struct {
+	Slot   string `json:"slot"`
+	ItemID string `json:"item_id"`
+	Wear   int    `json:"wear,omitempty"`
+}
+
+// QuestProgress is one entry of a character's quest log. Progress holds a
+// counter per quest objective, in definition order.
+type QuestProgress struct {
+	QuestID  string `json:"quest_id"`
+	Status   string `json:"status"`
+	Progress []int  `json:"progress"`
+}
@@ -0,0 +1,77 @@
+package character
+
+import (
+	"sort"
+	"strings"
+
+	domainworld "mmorp-server/internal/domain/world"
+)
+
+const DefaultClass = "adventurer"
+
+const (
+	ResourceMana   = "mana"
+	ResourceEnergy = "energy"
+)
+
+// Class is a playable class. Stats at level L, including HP regeneration,
+// are BaseStats plus StatsPerLevel scaled by L-1; Abilities lists the
+// ability IDs the class can cast, paid for with Resource, which regenerates
+// ResourceRegen per second.
+type Class struct {
+	ID               string            `json:"id"`
+	Name             string            `json:"name"`
+	Description      string            `json:"description"`
+	BaseStats        domainworld.Stats `json:"base_stats"`
+	StatsPerLevel    domainworld.Stats `json:"stats_per_level"`
+	AttackRange      float64           `json:"attack_range"`
+	Abilities        []string          `json:"abilities"`
+	Resource         string            `json:"resource"`
+	BaseResource     int               `json:"base_resource"`
+	ResourcePerLevel int               `json:"resource_per_level"`
+	ResourceRegen    int               `json:"resource_regen"`
+}
+
+func (c Class) StatsAt(level int) domainworld.Stats {
+	return c.BaseStats.Add(c.StatsPerLevel.Scale(level - 1))
+}
+
+func (c Class) MaxResourceAt(level int) int {
+	return c.BaseResource + c.ResourcePerLevel*(level-1)
+}
+
+func (c Class) HasAbility(id string) bool {
+	for _, a := range c.Abilities {
+		if a == id {
+			return true
+		}
+	}
+	return false
+}
+
+// ClassCatalog holds the playable classes by ID.
+type ClassCatalog map[string]Class
+
+// Lookup finds a class by ID, ignoring case.
+func (c ClassCatalog) Lookup(id string) (Class, bool) {
+	cls, ok := c[strings.ToLower(strings.TrimSpace(id))]
+	return cls, ok
+}
+
+// OrDefault resolves a stored class ID, falling back to the default class
+// for characters created before classes were validated.
+func (c ClassCatalog) OrDefault(id string) Class {
+	if cls, ok := c.Lookup(id); ok {
+		return cls
+	}
+	return c[DefaultClass]
+}
+
+func (c ClassCatalog) List() []Class {
+	out := make([]Class, 0, len(c))
+	for _, cls := range c {
+		out = append(out, cls)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
@@ -14,22 +14,232 @@ const (
 type InteractionType string
 
 const (
-	InteractionTypeTalk  InteractionType = "talk"
-	InteractionTypeTrade InteractionType = "trade"
-	InteractionTypeQuest InteractionType = "quest"
-	InteractionTypeHeal  InteractionType = "heal"
+	InteractionTypeTalk   InteractionType = "talk"
+	InteractionTypeTrade  InteractionType = "trade"
+	InteractionTypeQuest  InteractionType = "quest"
+	InteractionTypeHeal   InteractionType = "heal"
+	InteractionTypeRepair InteractionType = "repair"
 )
 
+type MobAIState string
+
+const (
+	MobAIStateIdle   MobAIState = "idle"
+	MobAIStateWander MobAIState = "wander"
+	MobAIStateChase  MobAIState = "chase"
+	MobAIStateAttack MobAIState = "attack"
+	MobAIStateEvade  MobAIState = "evade"
+	MobAIStateDead   MobAIState = "dead"
+)
+
+type ItemRarity string
+
+const (
+	ItemRarityCommon    ItemRarity = "common"
+	ItemRarityUncommon  ItemRarity = "uncommon"
+	ItemRarityRare      ItemRarity = "rare"
+	ItemRarityEpic      ItemRarity = "epic"
+	ItemRarityLegendary ItemRarity = "legendary"
+)
+
+func (r ItemRarity) Valid() bool {
+	switch r {
+	case ItemRarityCommon, ItemRarityUncommon, ItemRarityRare, ItemRarityEpic, ItemRarityLegendary:
+		return true
+	}
+	return false
+}
+
+type ItemKind string
+
+const (
+	ItemKindConsumable ItemKind = "consumable"
+	ItemKindMaterial   ItemKind = "material"
+	ItemKindWeapon     ItemKind = "weapon"
+	ItemKindArmor      ItemKind = "armor"
+	ItemKindTrinket    ItemKind = "trinket"
+)
+
+type EquipSlot string
+
+const (
+	EquipSlotWeapon  EquipSlot = "weapon"
+	EquipSlotHead    EquipSlot = "head"
+	EquipSlotChest   EquipSlot = "chest"
+	EquipSlotLegs    EquipSlot = "legs"
+	EquipSlotFeet    EquipSlot = "feet"
+	EquipSlotTrinket EquipSlot = "trinket"
+)
+
+func (s EquipSlot) Valid() bool {
+	switch s {
+	case EquipSlotWeapon, EquipSlotHead, EquipSlotChest, EquipSlotLegs, EquipSlotFeet, EquipSlotTrinket:
+		return true
+	}
+	return false
+}
+
+// Stats are a player's derived stats. HPRegen is the HP regained per second
+// out of combat.
+type Stats struct {
+	AttackPower int `json:"attack_power"`
+	Armor       int `json:"armor"`
+	MaxHP       int `json:"max_hp"`
+	HPRegen     int `json:"hp_regen"`
+}
+
+func (s Stats) Add(o Stats) Stats {
+	return Stats{AttackPower: s.AttackPower + o.AttackPower, Armor: s.Armor + o.Armor, MaxHP: s.MaxHP + o.MaxHP, HPRegen: s.HPRegen + o.HPRegen}
+}
+
+func (s Stats) Scale(n int) Stats {
+	return Stats{AttackPower: s.AttackPower * n, Armor: s.Armor * n, MaxHP: s.MaxHP * n, HPRegen: s.HPRegen * n}
+}
+
+type Item struct {
+	ID          string     `json:"id"`
+	Name        string     `json:"name"`
+	Description string     `json:"description,omitempty"`
+	Kind        ItemKind   `json:"kind"`
+	Rarity      ItemRarity `json:"rarity"`
+	MaxStack    int        `json:"max_stack"`
+	Value       int        `json:"value"`
+	Heal        int        `json:"heal,omitempty"`
+	// Food heals Heal per second while the player rests instead of at once,
+	// and cannot be eaten in combat.
+	Food    bool      `json:"food,omitempty"`
+	Slot    EquipSlot `json:"slot,omitempty"`
+	Stats   Stats     `json:"stats"`
+	Effects []string  `json:"effects,omitempty"`
+	// Durability is how much wear equipped gear takes from deaths before it
+	// breaks and stops giving stats; 0 means it never wears.
+	Durability int `json:"durability,omitempty"`
+}
+
+type QuestObjectiveType string
+
+const (
+	QuestObjectiveKill    QuestObjectiveType = "kill"
+	QuestObjectiveTalk    QuestObjectiveType = "talk"
+	QuestObjectiveCollect QuestObjectiveType = "collect"
+)
+
+// QuestObjective targets a mob template ID (kill), an NPC ID (talk) or an
+// item ID (collect).
+type QuestObjective struct {
+	Type   QuestObjectiveType `json:"type"`
+	Target string             `json:"target"`
+	Count  int                `json:"count"`
+}
+
+type QuestRewardItem struct {
+	ItemID   string `json:"item_id"`
+	Quantity int    `json:"quantity"`
+}
+
+type QuestRewards struct {
+	XP    int               `json:"xp"`
+	Gold  int               `json:"gold"`
+	Items []QuestRewardItem `json:"items,omitempty"`
+}
+
+type Quest struct {
+	ID          string           `json:"id"`
+	Name        string           `json:"name"`
+	Description string           `json:"description"`
+	GiverID     string           `json:"giver"`
+	TurnInID    string           `json:"turn_in"`
+	MinLevel    int              `json:"min_level"`
+	Objectives  []QuestObjective `json:"objectives"`
+	Rewards     QuestRewards     `json:"rewards"`
+}
+
+type DialogueConditionType string
+
+const (
+	DialogueConditionMinLevel    DialogueConditionType = "min_level"
+	DialogueConditionMaxLevel    DialogueConditionType = "max_level"
+	DialogueConditionMinGold     DialogueConditionType = "min_gold"
+	DialogueConditionQuestStatus DialogueConditionType = "quest_status"
+)
+
+// DialogueCondition gates a choice. Value is the level or gold threshold;
+// quest_status compares the player's status for QuestID with Status
+// (available, active, ready or completed).
+type DialogueCondition struct {
+	Type    DialogueConditionType `json:"type"`
+	Value   int                   `json:"value,omitempty"`
+	QuestID string                `json:"quest_id,omitempty"`
+	Status  string                `json:"status,omitempty"`
+}
+
+type DialogueActionType string
+
+const (
+	DialogueActionOpenShop    DialogueActionType = "open_shop"
+	DialogueActionGrantQuest  DialogueActionType = "grant_quest"
+	DialogueActionTurnInQuest DialogueActionType = "turn_in_quest"
+)
+
+type DialogueAction struct {
+	Type    DialogueActionType `json:"type"`
+	QuestID string             `json:"quest_id,omitempty"`
+}
+
+// DialogueChoice leads to the Next node, or ends the conversation when Next
+// is empty.
+type DialogueChoice struct {
+	Text       string              `json:"text"`
+	Next       string              `json:"next,omitempty"`
+	Conditions []DialogueCondition `json:"conditions,omitempty"`
+	Actions    []DialogueAction    `json:"actions,omitempty"`
+}
+
+type DialogueNode struct {
+	Text    string           `json:"text"`
+	Choices []DialogueChoice `json:"choices"`
+}
+
+type Dialogue struct {
+	ID    string                  `json:"id"`
+	Start string                  `json:"start"`
+	Nodes map[string]DialogueNode `json:"nodes"`
+}
+
 type SpawnPoint struct {
 	X float64 `json:"x"`
 	Y float64 `json:"y"`
 }
 
+type Portal struct {
+	ID           string     `json:"id"`
+	X            float64    `json:"x"`
+	Y            float64    `json:"y"`
+	Width        float64    `json:"width"`
+	Height       float64    `json:"height"`
+	TargetZoneID string     `json:"target_zone"`
+	TargetSpawn  SpawnPoint `json:"target_spawn"`
+}
+
+func (p Portal) Contains(x, y float64) bool {
+	return x >= p.X && x < p.X+p.Width && y >= p.Y && y < p.Y+p.Height
+}
+
+// Graveyard is where released players respawn.
+type Graveyard struct {
+	ID   string  `json:"id"`
+	Name string  `json:"name,omitempty"`
+	X    float64 `json:"x"`
+	Y    float64 `json:"y"`
+}
+
 type TileMap struct {
-	Width  int          `json:"width"`
-	Height int          `json:"height"`
-	Spawn  SpawnPoint   `json:"spawn"`
-	Tiles  [][]TileType `json:"tiles"`
+	Width      int          `json:"width"`
+	Height     int          `json:"height"`
+	Spawn      SpawnPoint   `json:"spawn"`
+	Tiles      [][]TileType `json:"tiles"`
+	Portals    []Portal     `json:"portals,omitempty"`
+	Graveyards []Graveyard  `json:"graveyards,omitempty"`
 }
 
 type PlayerState struct {
@@ -44,33 +254,226 @@ type PlayerState struct {
 	Experience int       `json:"experience"`
 	Gold       int       `json:"gold"`
 	ZoneID     string    `json:"zone_id"`
+	Stats      Stats     `json:"stats"`
+	// Resource is the mana or energy spent on abilities; ResourceType names
+	// which.
+	ResourceType string `json:"resource_type"`
+	Resource     int    `json:"resource"`
+	MaxResource  int    `json:"max_resource"`
+	Resting      bool   `json:"resting,omitempty"`
+	// Dead players have 0 HP until they are revived. A Ghost has released
+	// and may walk back to its Corpse.
+	Dead   bool        `json:"dead,omitempty"`
+	Ghost  bool        `json:"ghost,omitempty"`
+	Corpse *SpawnPoint `json:"corpse,omitempty"`
+	// XPDebt is experience owed for dying; half of all XP earned pays it off.
+	XPDebt int `json:"xp_debt,omitempty"`
+	// Equipment, Wear and Effects are replaced, never mutated in place, so
+	// copies of a PlayerState can be marshalled outside the zone lock.
+	Equipment map[EquipSlot]string `json:"equipment"`
+	Wear      map[EquipSlot]int    `json:"wear,omitempty"`
+	Effects   []ActiveEffect       `json:"effects,omitempty"`
+}
+
+type AbilityKind string
+
+const (
+	AbilityKindDamage AbilityKind = "damage"
+	AbilityKindHeal   AbilityKind = "heal"
+)
+
+type AbilityTarget string
+
+const (
+	AbilityTargetEnemy AbilityTarget = "enemy"
+	AbilityTargetSelf  AbilityTarget = "self"
+	AbilityTargetAlly  AbilityTarget = "ally"
+)
+
+// Ability is a castable skill. Its amount is Power plus the caster's attack
+// power scaled by AttackPowerScale. A Radius makes it hit every enemy around
+// the target, or around the caster for self-targeted abilities.
+type Ability struct {
+	ID               string        `json:"id"`
+	Name             string        `json:"name"`
+	Description      string        `json:"description"`
+	Kind             AbilityKind   `json:"kind"`
+	Target           AbilityTarget `json:"target"`
+	Range            float64       `json:"range"`
+	Radius           float64       `json:"radius,omitempty"`
+	Power            int           `json:"power"`
+	AttackPowerScale float64       `json:"attack_power_scale"`
+	CooldownTicks    int           `json:"cooldown_ticks"`
+	CastTicks        int           `json:"cast_ticks"`
+	Cost             int           `json:"cost"`
+	Effects          []string      `json:"effects,omitempty"`
+}
+
+type EffectKind string
+
+const (
+	EffectKindStat EffectKind = "stat"
+	EffectKindDoT  EffectKind = "dot"
+	EffectKindHoT  EffectKind = "hot"
+	EffectKindStun EffectKind = "stun"
+	EffectKindRoot EffectKind = "root"
+	EffectKindSlow EffectKind = "slow"
+)
+
+// EffectStacking decides what reapplying an active effect does: refresh its
+// duration, add a stack (up to MaxStacks) and refresh, or nothing.
+type EffectStacking string
+
+const (
+	EffectStackingRefresh EffectStacking = "refresh"
+	EffectStackingStack   EffectStacking = "stack"
+	EffectStackingIgnore  EffectStacking = "ignore"
+)
+
+// StatusEffect is a buff or debuff definition. Stats are added per stack;
+// damage and healing over time deal Amount per stack every IntervalTicks;
+// Slow is the fraction of move speed taken away.
+type StatusEffect struct {
+	ID            string         `json:"id"`
+	Name          string         `json:"name"`
+	Description   string         `json:"description,omitempty"`
+	Kind          EffectKind     `json:"kind"`
+	DurationTicks int            `json:"duration_ticks"`
+	IntervalTicks int            `json:"interval_ticks,omitempty"`
+	Amount        int            `json:"amount,omitempty"`
+	Stats         Stats          `json:"stats"`
+	Slow          float64        `json:"slow,omitempty"`
+	Stacking      EffectStacking `json:"stacking"`
+	MaxStacks     int            `json:"max_stacks"`
+}
+
+// ActiveEffect is an effect running on a player or mob until ExpiresTick.
+// SourceID is the player or mob that applied it.
+type ActiveEffect struct {
+	ID          string     `json:"id"`
+	Kind        EffectKind `json:"kind"`
+	Stacks      int        `json:"stacks"`
+	ExpiresTick uint64     `json:"expires_tick"`
+	SourceID    string     `json:"source_id"`
+	NextTick    uint64     `json:"-"`
+}
+
+type StockItem struct {
+	ItemID string `json:"item_id"`
+	Name   string `json:"name"`
+	Price  int    `json:"price"`
 }
 
 type NPC struct {
-	ID           string   `json:"id"`
-	Name         string   `json:"name"`
-	Role         string   `json:"role"`
-	Interactions []string `json:"interactions"`
-	Dialogue     string   `json:"dialogue,omitempty"`
-	TradeItems   []string `json:"trade_items,omitempty"`
-	QuestInfo    string   `json:"quest_info,omitempty"`
-	GoldPrice    int      `json:"gold_price,omitempty"`
-	X            float64  `json:"x"`
-	Y            float64  `json:"y"`
-	ZoneID       string   `json:"zone_id"`
+	ID           string      `json:"id"`
+	Name         string      `json:"name"`
+	Role         string      `json:"role"`
+	Interactions []string    `json:"interactions"`
+	Dialogue     string      `json:"dialogue,omitempty"`
+	DialogueID   string      `json:"dialogue_id,omitempty"`
+	Stock        []StockItem `json:"stock,omitempty"`
+	GoldPrice    int         `json:"gold_price,omitempty"`
+	X            float64     `json:"x"`
+	Y            float64     `json:"y"`
+	ZoneID       string      `json:"zone_id"`
+}
+
+type MobTemplate struct {
+	ID                  string    `json:"id"`
+	Name                string    `json:"name"`
+	Level               int       `json:"level"`
+	HP                  int       `json:"hp"`
+	Damage              int       `json:"damage"`
+	MoveSpeed           float64   `json:"move_speed"`
+	AggroRange          float64   `json:"aggro_range"`
+	AttackRange         float64   `json:"attack_range"`
+	AttackCooldownTicks int       `json:"attack_cooldown_ticks"`
+	RespawnTicks        int       `json:"respawn_ticks"`
+	XPReward            int       `json:"xp_reward"`
+	Loot                LootTable `json:"loot"`
+	// Attacks default to a single melee attack at AttackRange every
+	// AttackCooldownTicks.
+	Attacks []MobAttack `json:"attacks,omitempty"`
+	// Effects are applied to the player on every hit.
+	Effects []string `json:"effects,omitempty"`
+}
+
+type MobAttackKind string
+
+const (
+	MobAttackMelee  MobAttackKind = "melee"
+	MobAttackRanged MobAttackKind = "ranged"
+	MobAttackSlam   MobAttackKind = "slam"
+	MobAttackEnrage MobAttackKind = "enrage"
+)
+
+// MobAttack is one move in a mob's repertoire. Melee and ranged attacks hit
+// the mob's target within Range; a slam hits every player within Radius of
+// the mob; an enrage applies Effects to the mob itself, once per fight.
+// HPBelow, a fraction of max HP, keeps an attack back until the mob is that
+// hurt. A zero Damage uses the mob's own damage.
+type MobAttack struct {
+	ID            string        `json:"id"`
+	Name          string        `json:"name"`
+	Kind          MobAttackKind `json:"kind"`
+	Range         float64       `json:"range,omitempty"`
+	Radius        float64       `json:"radius,omitempty"`
+	Damage        int           `json:"damage,omitempty"`
+	CooldownTicks int           `json:"cooldown_ticks"`
+	HPBelow       float64       `json:"hp_below,omitempty"`
+	Effects       []string      `json:"effects,omitempty"`
+}
+
+// LootTable is rolled when a mob dies: gold uniformly in [GoldMin, GoldMax]
+// and Rolls weighted picks from Drops. A drop without an ItemID is "nothing".
+type LootTable struct {
+	GoldMin int        `json:"gold_min"`
+	GoldMax int        `json:"gold_max"`
+	Rolls   int        `json:"rolls"`
+	Drops   []LootDrop `json:"drops"`
+}
+
+type LootDrop struct {
+	ItemID      string     `json:"item_id"`
+	Weight      int        `json:"weight"`
+	MinQuantity int        `json:"min_quantity"`
+	MaxQuantity int        `json:"max_quantity"`
+	Rarity      ItemRarity `json:"rarity"`
+}
+
+type LootItem struct {
+	ItemID   string     `json:"item_id"`
+	Quantity int        `json:"quantity"`
+	Rarity   ItemRarity `json:"rarity"`
+	Wear     int        `json:"wear,omitempty"`
+}
+
+type GroundLoot struct {
+	ID      string     `json:"id"`
+	X       float64    `json:"x"`
+	Y       float64    `json:"y"`
+	OwnerID uuid.UUID  `json:"owner_id"`
+	Items   []LootItem `json:"items"`
+	ZoneID  string     `json:"zone_id"`
 }
 
 type MobState struct {
-	ID           string  `json:"id"`
-	Name         string  `json:"name"`
-	X            float64 `json:"x"`
-	Y            float64 `json:"y"`
-	HP           int     `json:"hp"`
-	MaxHP        int     `json:"max_hp"`
-	Damage       int     `json:"damage"`
-	PatrolRadius float64 `json:"patrol_radius"`
-	ZoneID       string  `json:"zone_id"`
-	Alive        bool    `json:"alive"`
+	ID           string     `json:"id"`
+	TemplateID   string     `json:"template_id"`
+	Name         string     `json:"name"`
+	Level        int        `json:"level"`
+	X            float64    `json:"x"`
+	Y            float64    `json:"y"`
+	HP           int        `json:"hp"`
+	MaxHP        int        `json:"max_hp"`
+	Damage       int        `json:"damage"`
+	PatrolRadius float64    `json:"patrol_radius"`
+	LeashRadius  float64    `json:"leash_radius"`
+	ZoneID       string     `json:"zone_id"`
+	Alive        bool       `json:"alive"`
+	AIState      MobAIState `json:"ai_state"`
+	// Effects is replaced, never mutated in place.
+	Effects []ActiveEffect `json:"effects,omitempty"`
 }
 
 type WorldState struct {
@@ -80,4 +483,5 @@ type WorldState struct {
 	Players []PlayerState `json:"players"`
 	NPCs    []NPC         `json:"npcs"`
 	Mobs    []MobState    `json:"mobs"`
+	Loot    []GroundLoot  `json:"loot"`
 }
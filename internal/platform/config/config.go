@@ -26,7 +26,7 @@ type Config struct {
 	NATSURL        string
 	WorldTickRate  int
 	WorldZoneID    string
-	WorldMapFile   string
+	WorldDataDir   string
 	MaxRequestBody int64
 }
 
@@ -49,7 +49,7 @@ func Load() (Config, error) {
 		NATSURL:        getEnv("NATS_URL", "nats://localhost:4222"),
 		WorldTickRate:  getInt("WORLD_TICK_RATE", 10),
 		WorldZoneID:    getEnv("WORLD_ZONE_ID", "starter-zone"),
-		WorldMapFile:   getEnv("WORLD_MAP_FILE", "data/maps/starter-zone.json"),
+		WorldDataDir:   getEnv("WORLD_DATA_DIR", "data"),
 		MaxRequestBody: getInt64("MAX_REQUEST_BODY_BYTES", 1<<20),
 	}
 	if cfg.JWTSecret == "" {
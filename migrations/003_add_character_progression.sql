@@ -0,0 +1,6 @@
+ALTER TABLE characters
+    ADD COLUMN IF NOT EXISTS level INTEGER NOT NULL DEFAULT 1,
+    ADD COLUMN IF NOT EXISTS experience INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS gold INTEGER NOT NULL DEFAULT 100,
+    ADD COLUMN IF NOT EXISTS hp INTEGER NOT NULL DEFAULT 100,
+    ADD COLUMN IF NOT EXISTS max_hp INTEGER NOT NULL DEFAULT 100;
@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS character_inventory (
+    character_id UUID NOT NULL REFERENCES characters(id) ON DELETE CASCADE,
+    slot INTEGER NOT NULL CHECK (slot >= 0),
+    item_id TEXT NOT NULL,
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    PRIMARY KEY (character_id, slot)
+);
@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS character_equipment (
+    character_id UUID NOT NULL REFERENCES characters(id) ON DELETE CASCADE,
+    slot TEXT NOT NULL,
+    item_id TEXT NOT NULL,
+    PRIMARY KEY (character_id, slot)
+);
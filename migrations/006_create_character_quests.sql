@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS character_quests (
+    character_id UUID NOT NULL REFERENCES characters(id) ON DELETE CASCADE,
+    quest_id TEXT NOT NULL,
+    status TEXT NOT NULL,
+    progress INTEGER[] NOT NULL DEFAULT '{}',
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (character_id, quest_id)
+);
@@ -0,0 +1,8 @@
+ALTER TABLE characters
+    ADD COLUMN IF NOT EXISTS xp_debt INTEGER NOT NULL DEFAULT 0;
+
+ALTER TABLE character_equipment
+    ADD COLUMN IF NOT EXISTS wear INTEGER NOT NULL DEFAULT 0;
+
+ALTER TABLE character_inventory
+    ADD COLUMN IF NOT EXISTS wear INTEGER NOT NULL DEFAULT 0;